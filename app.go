@@ -3,11 +3,22 @@
 package mdl
 
 import (
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+
 	"git.zzdats.lv/edim/api-mdl/csdd"
+	"git.zzdats.lv/edim/api-mdl/encryption"
+	"git.zzdats.lv/edim/api-mdl/mdoc"
+	"git.zzdats.lv/edim/api-mdl/sdjwt"
+	"git.zzdats.lv/edim/api-mdl/signing"
+	"git.zzdats.lv/edim/api-mdl/tracing"
 	"git.zzdats.lv/edim/api-mdl/vault"
 
 	"azugo.io/azugo"
 	"azugo.io/azugo/server"
+	"github.com/nobid-lsp-latvia/go-idauth"
 	"github.com/spf13/cobra"
 )
 
@@ -15,13 +26,31 @@ import (
 type App struct {
 	*azugo.App
 
-	config *Configuration
-	vault  vault.Service
-	csdd   csdd.Service
+	config    *Configuration
+	vault     vault.Service
+	csdd      csdd.Service
+	mdoc      *mdoc.Issuer
+	sdjwt     *sdjwt.Issuer
+	signer    *signing.Signer
+	encrypter *encryption.Encrypter
+	auth      azugo.RequestHandlerFunc
+
+	// shuttingDown is set as soon as termination signal is received.
+	shuttingDown atomic.Bool
+}
+
+// Option configures the application instance.
+type Option func(a *App)
+
+// WithAuthentication replaces idAuth authentication middleware of the API routes.
+func WithAuthentication(auth azugo.RequestHandlerFunc) Option {
+	return func(a *App) {
+		a.auth = auth
+	}
 }
 
 // New returns a new application instance.
-func New(cmd *cobra.Command, version string) (*App, error) {
+func New(cmd *cobra.Command, version string, opts ...Option) (*App, error) {
 	config := NewConfiguration()
 
 	a, err := server.New(cmd, server.Options{
@@ -38,6 +67,10 @@ func New(cmd *cobra.Command, version string) (*App, error) {
 		config: config,
 	}
 
+	for _, opt := range opts {
+		opt(instance)
+	}
+
 	err = instance.InitServices()
 	if err != nil {
 		return nil, err
@@ -49,6 +82,10 @@ func New(cmd *cobra.Command, version string) (*App, error) {
 func (a *App) InitServices() error {
 	var err error
 
+	if err = tracing.New(a.App.App, a.config.Tracing); err != nil {
+		return err
+	}
+
 	a.vault, err = vault.New(a.App.App, a.config.Vault)
 	if err != nil {
 		return err
@@ -59,6 +96,32 @@ func (a *App) InitServices() error {
 		return err
 	}
 
+	if a.config.MDoc.Enabled() {
+		a.mdoc, err = mdoc.New(a.config.MDoc)
+		if err != nil {
+			return err
+		}
+	}
+
+	if a.config.SDJWT.Enabled() {
+		a.sdjwt, err = sdjwt.New(a.config.SDJWT)
+		if err != nil {
+			return err
+		}
+	}
+
+	if a.config.Signing.Enabled() {
+		a.signer, err = signing.New(a.config.Signing)
+		if err != nil {
+			return err
+		}
+	}
+
+	a.encrypter, err = encryption.New(a.config.Encryption)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -70,6 +133,59 @@ func (a *App) CsddService() csdd.Service {
 	return a.csdd
 }
 
+// MDocIssuer returns mdoc issuer or nil if mdoc issuance is not configured.
+func (a *App) MDocIssuer() *mdoc.Issuer {
+	return a.mdoc
+}
+
+// SDJWTIssuer returns SD-JWT VC issuer or nil if SD-JWT VC issuance is not configured.
+func (a *App) SDJWTIssuer() *sdjwt.Issuer {
+	return a.sdjwt
+}
+
+// ResponseSigner returns response signer or nil if response signing is not configured.
+func (a *App) ResponseSigner() *signing.Signer {
+	return a.signer
+}
+
+// ResponseEncrypter returns response encrypter.
+func (a *App) ResponseEncrypter() *encryption.Encrypter {
+	return a.encrypter
+}
+
+// Authentication returns middleware that authenticates requests with idAuth.
+func (a *App) Authentication() azugo.RequestHandlerFunc {
+	if a.auth != nil {
+		return a.auth
+	}
+
+	return idauth.Authentication(a.App, a.Config().IDAuth)
+}
+
+// WatchShutdown marks application as shutting down as soon as termination signal is received,
+// so that it is removed from load balancing before server is stopped.
+//
+// Must be called once when server is started.
+func (a *App) WatchShutdown() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGTERM, os.Interrupt)
+
+	go func() {
+		<-sig
+		a.shuttingDown.Store(true)
+	}()
+}
+
+// ShuttingDown returns true if termination signal has been received.
+func (a *App) ShuttingDown() bool {
+	return a.shuttingDown.Load()
+}
+
+// ConfigLoaded returns true if configuration is loaded.
+func (a *App) ConfigLoaded() bool {
+	return a.config != nil && a.config.Ready()
+}
+
 // Config returns application configuration.
 //
 // Panics if configuration is not loaded.
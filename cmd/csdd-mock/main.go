@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"git.zzdats.lv/edim/api-mdl/csdd/csddmock"
+
+	"github.com/spf13/cobra"
+)
+
+var rootCmd = &cobra.Command{
+	Use:   "csdd-mock",
+	Short: "CSDD service stand-in",
+	Long: `Serve CSDD Chk_web_Gliet, Qry_va, Upd_web_parole and Del_Fses services
+with fixture users and persons for local development and tests`,
+	RunE:          run,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+func run(cmd *cobra.Command, _ []string) error {
+	addr, _ := cmd.Flags().GetString("listen")
+	path, _ := cmd.Flags().GetString("fixtures")
+
+	fixtures := csddmock.DefaultFixtures()
+
+	if path != "" {
+		var err error
+
+		fixtures, err = csddmock.LoadFixtures(path)
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Printf("CSDD mock listening on %s with %d users, %d persons and %d faults",
+		addr, len(fixtures.Users), len(fixtures.Persons), len(fixtures.Faults))
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           csddmock.New(fixtures),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+func main() {
+	rootCmd.Flags().StringP("listen", "l", ":8090", "address to listen on")
+	rootCmd.Flags().StringP("fixtures", "f", "", "path to fixtures file, built-in fixtures are used if not set")
+
+	if err := rootCmd.Execute(); err != nil {
+		log.Fatal(err)
+	}
+}
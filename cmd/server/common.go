@@ -38,5 +38,13 @@ func (h *HealthCheck) RunHealthCheck(cmd *cobra.Command) error {
 
 	client := &fasthttp.Client{}
 
-	return client.Do(req, resp)
+	if err := client.Do(req, resp); err != nil {
+		return err
+	}
+
+	if resp.StatusCode() >= fasthttp.StatusBadRequest {
+		return fmt.Errorf("health check %s failed with status %d", h.Path, resp.StatusCode())
+	}
+
+	return nil
 }
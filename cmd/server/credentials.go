@@ -0,0 +1,48 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	app "git.zzdats.lv/edim/api-mdl"
+
+	"github.com/spf13/cobra"
+)
+
+// credentialsCmd represents the credentials command.
+var credentialsCmd = &cobra.Command{
+	Use:   "credentials",
+	Short: "Show CSDD credential status",
+	Long: `Show CSDD technical user password Vault version, its age
+and the state of the last password rotation`,
+	RunE:          runCredentials,
+	SilenceErrors: true,
+}
+
+func runCredentials(cmd *cobra.Command, _ []string) error {
+	a, err := app.New(cmd, Version)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	status, err := a.CsddService().CredentialStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(cmd.OutOrStdout())
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(status)
+}
+
+func init() {
+	initRootCmd()
+	RootCmd.AddCommand(credentialsCmd)
+}
@@ -10,14 +10,22 @@ import (
 var healthCmd = &cobra.Command{
 	Use:           "health",
 	Short:         "Check health of the server",
-	Long:          `Check if the web server is running and responding to healthz request`,
+	Long:          `Check if the web server is running and responding to healthz, readyz or livez request`,
 	RunE:          runHealth,
 	SilenceErrors: true,
 }
 
 func runHealth(cmd *cobra.Command, _ []string) error {
+	path := "/healthz"
+
+	if ready, _ := cmd.Flags().GetBool("ready"); ready {
+		path = "/readyz"
+	} else if live, _ := cmd.Flags().GetBool("live"); live {
+		path = "/livez"
+	}
+
 	checker := &HealthCheck{
-		Path:    "/healthz",
+		Path:    path,
 		Version: Version,
 	}
 
@@ -26,5 +34,10 @@ func runHealth(cmd *cobra.Command, _ []string) error {
 
 func init() {
 	initRootCmd()
+
+	healthCmd.Flags().Bool("ready", false, "check if server is ready to serve requests")
+	healthCmd.Flags().Bool("live", false, "check only if server process is alive")
+	healthCmd.MarkFlagsMutuallyExclusive("ready", "live")
+
 	RootCmd.AddCommand(healthCmd)
 }
@@ -0,0 +1,47 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	app "git.zzdats.lv/edim/api-mdl"
+
+	"github.com/spf13/cobra"
+)
+
+// rotatePasswordCmd represents the rotate-password command.
+var rotatePasswordCmd = &cobra.Command{
+	Use:   "rotate-password",
+	Short: "Rotate CSDD password",
+	Long: `Change CSDD technical user password regardless of its age
+and save the new password to Vault`,
+	RunE:          runRotatePassword,
+	SilenceErrors: true,
+}
+
+func runRotatePassword(cmd *cobra.Command, _ []string) error {
+	a, err := app.New(cmd, Version)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	version, err := a.CsddService().RotatePassword(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "CSDD password rotated, Vault secret version: %d\n", version)
+
+	return nil
+}
+
+func init() {
+	initRootCmd()
+	RootCmd.AddCommand(rotatePasswordCmd)
+}
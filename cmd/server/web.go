@@ -29,6 +29,8 @@ func runWeb(cmd *cobra.Command, _ []string) error {
 		return err
 	}
 
+	a.WatchShutdown()
+
 	server.Run(a)
 
 	return nil
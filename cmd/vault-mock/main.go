@@ -0,0 +1,84 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"git.zzdats.lv/edim/api-mdl/vault/vaultmock"
+
+	"github.com/spf13/cobra"
+)
+
+var rootCmd = &cobra.Command{
+	Use:   "vault-mock",
+	Short: "Vault stand-in",
+	Long: `Serve Vault AppRole login and KV v2 secrets engine
+for local development and tests`,
+	RunE:          run,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+func run(cmd *cobra.Command, _ []string) error {
+	addr, _ := cmd.Flags().GetString("listen")
+	roleID, _ := cmd.Flags().GetString("role-id")
+	secretID, _ := cmd.Flags().GetString("secret-id")
+	ttl, _ := cmd.Flags().GetDuration("token-ttl")
+	maxVersions, _ := cmd.Flags().GetInt("max-versions")
+	secrets, _ := cmd.Flags().GetStringArray("secret")
+
+	mock := vaultmock.New(vaultmock.Options{
+		RoleID:      roleID,
+		SecretID:    secretID,
+		TokenTTL:    ttl,
+		MaxVersions: maxVersions,
+	})
+
+	for _, s := range secrets {
+		path, kv, ok := strings.Cut(s, ":")
+		key, value, ok2 := strings.Cut(kv, "=")
+
+		if !ok || !ok2 {
+			return fmt.Errorf("invalid secret %q, expected path:key=value", s)
+		}
+
+		version := mock.Put(path, map[string]any{key: value})
+
+		log.Printf("Secret %s version %d", path, version)
+	}
+
+	log.Printf("Vault mock listening on %s", addr)
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mock,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+func main() {
+	rootCmd.Flags().StringP("listen", "l", ":8200", "address to listen on")
+	rootCmd.Flags().String("role-id", "api-mdl", "AppRole role ID")
+	rootCmd.Flags().String("secret-id", "api-mdl-secret", "AppRole secret ID")
+	rootCmd.Flags().Duration("token-ttl", time.Hour, "lifetime of issued tokens, 0 for no expiry")
+	rootCmd.Flags().Int("max-versions", 10, "number of secret versions kept")
+	rootCmd.Flags().StringArray("secret", []string{
+		"secret/csdd:edim-csdd-service-password=Tests-Parole-2025",
+	}, "initial secret as path:key=value, path consists of mount and secret path")
+
+	if err := rootCmd.Execute(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -6,6 +6,11 @@ import (
 	"time"
 
 	"git.zzdats.lv/edim/api-mdl/csdd"
+	"git.zzdats.lv/edim/api-mdl/encryption"
+	"git.zzdats.lv/edim/api-mdl/mdoc"
+	"git.zzdats.lv/edim/api-mdl/sdjwt"
+	"git.zzdats.lv/edim/api-mdl/signing"
+	"git.zzdats.lv/edim/api-mdl/tracing"
 	"git.zzdats.lv/edim/api-mdl/vault"
 
 	"azugo.io/azugo/config"
@@ -18,9 +23,14 @@ import (
 type Configuration struct {
 	*config.Configuration `mapstructure:",squash"`
 
-	Vault  *vault.Configuration  `mapstructure:"vault"`
-	CSDD   *csdd.Configuration   `mapstructure:"csdd"`
-	IDAuth *idauth.Configuration `mapstructure:"idauth"`
+	Vault      *vault.Configuration      `mapstructure:"vault"`
+	CSDD       *csdd.Configuration       `mapstructure:"csdd"`
+	IDAuth     *idauth.Configuration     `mapstructure:"idauth"`
+	Tracing    *tracing.Configuration    `mapstructure:"tracing"`
+	MDoc       *mdoc.Configuration       `mapstructure:"mdoc"`
+	SDJWT      *sdjwt.Configuration      `mapstructure:"sdjwt"`
+	Signing    *signing.Configuration    `mapstructure:"signing"`
+	Encryption *encryption.Configuration `mapstructure:"encryption"`
 }
 
 // NewConfiguration returns a new configuration.
@@ -42,6 +52,11 @@ func (c *Configuration) Bind(_ string, v *viper.Viper) {
 	c.Vault = config.Bind(c.Vault, "vault", v)
 	c.CSDD = config.Bind(c.CSDD, "csdd", v)
 	c.IDAuth = config.Bind(c.IDAuth, "idauth", v)
+	c.Tracing = config.Bind(c.Tracing, "tracing", v)
+	c.MDoc = config.Bind(c.MDoc, "mdoc", v)
+	c.SDJWT = config.Bind(c.SDJWT, "sdjwt", v)
+	c.Signing = config.Bind(c.Signing, "signing", v)
+	c.Encryption = config.Bind(c.Encryption, "encryption", v)
 }
 
 // Validate application configuration.
@@ -58,6 +73,26 @@ func (c *Configuration) Validate(validate *validation.Validate) error {
 		return err
 	}
 
+	if err := c.Tracing.Validate(validate); err != nil {
+		return err
+	}
+
+	if err := c.MDoc.Validate(validate); err != nil {
+		return err
+	}
+
+	if err := c.SDJWT.Validate(validate); err != nil {
+		return err
+	}
+
+	if err := c.Signing.Validate(validate); err != nil {
+		return err
+	}
+
+	if err := c.Encryption.Validate(validate); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -0,0 +1,45 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package csdd
+
+import (
+	"context"
+	"errors"
+
+	"git.zzdats.lv/edim/api-mdl/metrics"
+
+	"github.com/sony/gobreaker/v2"
+	"go.uber.org/zap"
+)
+
+// newBreaker returns circuit breaker that opens after configured number of consecutive failed CSDD calls.
+//
+// Only failures to reach CSDD are counted, errors returned in CSDD response mean that CSDD is working.
+func (s *csddService) newBreaker() *gobreaker.CircuitBreaker[struct{}] {
+	return gobreaker.NewCircuitBreaker[struct{}](gobreaker.Settings{
+		Name:        "csdd",
+		MaxRequests: 1,
+		Timeout:     s.config.BreakerOpenTimeout,
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			return counts.ConsecutiveFailures >= uint32(s.config.BreakerFailures)
+		},
+		IsExcluded: func(err error) bool {
+			return errors.Is(err, context.Canceled)
+		},
+		OnStateChange: func(_ string, from gobreaker.State, to gobreaker.State) {
+			metrics.SetCSDDCircuitState(int(to))
+
+			log := s.app.Log().With(zap.String("from", from.String()), zap.String("to", to.String()))
+			if to == gobreaker.StateOpen {
+				log.Error("CSDD circuit breaker opened", zap.Duration("timeout", s.config.BreakerOpenTimeout))
+			} else {
+				log.Info("CSDD circuit breaker state changed")
+			}
+		},
+	})
+}
+
+// CircuitState returns the state of CSDD circuit breaker: closed, half-open or open.
+func (s *csddService) CircuitState() string {
+	return s.breaker.State().String()
+}
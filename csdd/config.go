@@ -3,6 +3,11 @@
 package csdd
 
 import (
+	"time"
+
+	"git.zzdats.lv/edim/api-mdl/upstream"
+
+	"azugo.io/azugo/config"
 	"azugo.io/core/validation"
 	"github.com/spf13/viper"
 )
@@ -13,19 +18,103 @@ type Configuration struct {
 	CSDDChangePasswordDays string `mapstructure:"csdd_change_password_days"`
 	CSDDSystemGUID         string `mapstructure:"csdd_system_guid"`
 	CSDDSystemName         string `mapstructure:"csdd_system_name"`
-	SkipVerify             bool   `mapstructure:"skip_verify"`
+
+	// Client is the HTTP client configuration for CSDD.
+	Client *upstream.Configuration `mapstructure:"client"`
+
+	// SessionPoolSize is the maximum number of CSDD sessions kept open at the same time.
+	SessionPoolSize int `mapstructure:"session_pool_size" validate:"min=1"`
+	// SessionIdleTimeout is the time after which idle CSDD session is not reused anymore.
+	SessionIdleTimeout time.Duration `mapstructure:"session_idle_timeout"`
+	// SessionMaxAge is the time after login after which CSDD session is not reused anymore regardless of its use.
+	SessionMaxAge time.Duration `mapstructure:"session_max_age" validate:"gte=0"`
+	// RotationInterval is the interval of background password rotation check.
+	RotationInterval time.Duration `mapstructure:"rotation_interval" validate:"gt=0"`
+	// RotationLockRedisURL is the Redis connection URL used for password rotation lock between multiple instances.
+	// If not set, service must be run as a single instance.
+	RotationLockRedisURL string `mapstructure:"rotation_lock_redis_url" validate:"omitempty,url"`
+	// RotationLockTTL is the time after which rotation lock is released if instance holding it has died.
+	RotationLockTTL time.Duration `mapstructure:"rotation_lock_ttl" validate:"gt=0"`
+	// RecoveryMaxVersions is the maximum number of prior Vault secret versions tried when CSDD does not accept password.
+	RecoveryMaxVersions int `mapstructure:"recovery_max_versions" validate:"min=1"`
+	// MaxLoginAttempts is the maximum number of failed CSDD login attempts during password recovery.
+	MaxLoginAttempts int `mapstructure:"max_login_attempts" validate:"min=2"`
+	// RecoveryBackoff is the time login is suspended after failed password recovery.
+	RecoveryBackoff time.Duration `mapstructure:"recovery_backoff"`
+
+	// LoginRetryAttempts is the maximum number of CSDD login attempts when CSDD can not be reached.
+	LoginRetryAttempts int `mapstructure:"login_retry_attempts" validate:"min=1"`
+	// QueryRetryAttempts is the maximum number of CSDD data query attempts when CSDD can not be reached.
+	QueryRetryAttempts int `mapstructure:"query_retry_attempts" validate:"min=1"`
+	// RetryInitialBackoff is the maximum delay before the first retry, doubled for every next retry.
+	RetryInitialBackoff time.Duration `mapstructure:"retry_initial_backoff" validate:"gt=0"`
+	// RetryMaxBackoff is the upper limit of the delay between retries.
+	RetryMaxBackoff time.Duration `mapstructure:"retry_max_backoff" validate:"gtefield=RetryInitialBackoff"`
+	// BreakerFailures is the number of consecutive failed CSDD calls after which circuit breaker opens.
+	BreakerFailures int `mapstructure:"breaker_failures" validate:"min=1"`
+	// BreakerOpenTimeout is the time calls to CSDD fail fast after circuit breaker has opened.
+	BreakerOpenTimeout time.Duration `mapstructure:"breaker_open_timeout" validate:"gt=0"`
+
+	// NotFoundCodes are additional CSDD error codes returned by Qry_va when there is no driving licence data about the person.
+	NotFoundCodes []string `mapstructure:"not_found_codes"`
+	// SessionExpiredCodes are additional CSDD error codes returned when session is not valid anymore.
+	SessionExpiredCodes []string `mapstructure:"session_expired_codes"`
+
+	PasswordPolicy *PasswordPolicy `mapstructure:"password_policy"`
 }
 
 func (c *Configuration) Bind(prefix string, v *viper.Viper) {
+	v.SetDefault(prefix+".session_pool_size", 2)
+	v.SetDefault(prefix+".session_idle_timeout", 5*time.Minute)
+	v.SetDefault(prefix+".session_max_age", time.Hour)
+	v.SetDefault(prefix+".rotation_interval", time.Hour)
+	v.SetDefault(prefix+".rotation_lock_ttl", 2*time.Minute)
+	v.SetDefault(prefix+".recovery_max_versions", 5)
+	v.SetDefault(prefix+".max_login_attempts", 3)
+	v.SetDefault(prefix+".recovery_backoff", 15*time.Minute)
+	v.SetDefault(prefix+".login_retry_attempts", 3)
+	v.SetDefault(prefix+".query_retry_attempts", 3)
+	v.SetDefault(prefix+".retry_initial_backoff", 200*time.Millisecond)
+	v.SetDefault(prefix+".retry_max_backoff", 2*time.Second)
+	v.SetDefault(prefix+".breaker_failures", 5)
+	v.SetDefault(prefix+".breaker_open_timeout", 30*time.Second)
+
 	_ = v.BindEnv(prefix+".csdd_change_password_days", "CSDD_CHANGE_PASSWORD_DAYS")
 	_ = v.BindEnv(prefix+".csdd_url", "CSDD_URL")
 	_ = v.BindEnv(prefix+".csdd_username", "CSDD_USERNAME")
 	_ = v.BindEnv(prefix+".csdd_change_password_days", "CSDD_CHANGE_PASSWORD_DAYS")
 	_ = v.BindEnv(prefix+".csdd_system_guid", "CSDD_SYSTEM_GUID")
 	_ = v.BindEnv(prefix+".csdd_system_name", "CSDD_SYSTEM_NAME")
-	_ = v.BindEnv(prefix+".skip_verify", "CSDD_SKIP_TLS_VERIFY")
+	_ = v.BindEnv(prefix+".session_pool_size", "CSDD_SESSION_POOL_SIZE")
+	_ = v.BindEnv(prefix+".session_idle_timeout", "CSDD_SESSION_IDLE_TIMEOUT")
+	_ = v.BindEnv(prefix+".session_max_age", "CSDD_SESSION_MAX_AGE")
+	_ = v.BindEnv(prefix+".rotation_interval", "CSDD_ROTATION_INTERVAL")
+	_ = v.BindEnv(prefix+".rotation_lock_redis_url", "CSDD_ROTATION_LOCK_REDIS_URL")
+	_ = v.BindEnv(prefix+".rotation_lock_ttl", "CSDD_ROTATION_LOCK_TTL")
+	_ = v.BindEnv(prefix+".recovery_max_versions", "CSDD_RECOVERY_MAX_VERSIONS")
+	_ = v.BindEnv(prefix+".max_login_attempts", "CSDD_MAX_LOGIN_ATTEMPTS")
+	_ = v.BindEnv(prefix+".recovery_backoff", "CSDD_RECOVERY_BACKOFF")
+	_ = v.BindEnv(prefix+".login_retry_attempts", "CSDD_LOGIN_RETRY_ATTEMPTS")
+	_ = v.BindEnv(prefix+".query_retry_attempts", "CSDD_QUERY_RETRY_ATTEMPTS")
+	_ = v.BindEnv(prefix+".retry_initial_backoff", "CSDD_RETRY_INITIAL_BACKOFF")
+	_ = v.BindEnv(prefix+".retry_max_backoff", "CSDD_RETRY_MAX_BACKOFF")
+	_ = v.BindEnv(prefix+".breaker_failures", "CSDD_BREAKER_FAILURES")
+	_ = v.BindEnv(prefix+".breaker_open_timeout", "CSDD_BREAKER_OPEN_TIMEOUT")
+	_ = v.BindEnv(prefix+".not_found_codes", "CSDD_NOT_FOUND_CODES")
+	_ = v.BindEnv(prefix+".session_expired_codes", "CSDD_SESSION_EXPIRED_CODES")
+
+	c.Client = upstream.Bind(c.Client, prefix+".client", "CSDD", v)
+	c.PasswordPolicy = config.Bind(c.PasswordPolicy, prefix+".password_policy", v)
 }
 
 func (c *Configuration) Validate(valid *validation.Validate) error {
-	return valid.Struct(c)
+	if err := valid.Struct(c); err != nil {
+		return err
+	}
+
+	if err := c.Client.Validate(valid); err != nil {
+		return err
+	}
+
+	return c.PasswordPolicy.Validate(valid)
 }
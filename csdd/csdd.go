@@ -3,63 +3,179 @@
 package csdd
 
 import (
-	"crypto/rand"
+	"context"
 	"errors"
-	"math/big"
-	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"git.zzdats.lv/edim/api-mdl/metrics"
 	"git.zzdats.lv/edim/api-mdl/routes/responses"
+	"git.zzdats.lv/edim/api-mdl/tracing"
+	"git.zzdats.lv/edim/api-mdl/upstream"
 	"git.zzdats.lv/edim/api-mdl/vault"
 
-	"azugo.io/azugo"
 	"azugo.io/core"
-	"azugo.io/core/http"
+	"github.com/sony/gobreaker/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
-const (
-	charsNumbers = "0123456789"
-	charsUppers  = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
-	charsLowers  = "abcdefghijklmnopqrstuvwxyz"
-	charsSpecial = "~!@-#$+?"
-)
-
 type csddService struct {
-	app    *core.App
-	config *Configuration
-	vault  vault.Service
+	app      *core.App
+	config   *Configuration
+	vault    vault.Service
+	sessions *sessionPool
+	http     *upstream.Client
+	// breaker fails CSDD calls fast while CSDD is unreachable.
+	breaker *gobreaker.CircuitBreaker[struct{}]
+	// lock guards changes of CSDD password across all service instances.
+	lock locker
+	// codes classifies CSDD error codes.
+	codes map[string]codeInfo
 
 	tokenMu sync.Mutex
+	// metadataMu serializes updates of Vault secret custom metadata.
+	metadataMu sync.Mutex
+
+	statusMu sync.Mutex
+	// recoveryFailed holds the state of the last failed login recovery.
+	recoveryFailed *recoveryFailure
+	// recoveryUnsaved is true if the state of login recovery has not been saved in Vault yet.
+	recoveryUnsaved bool
+	// recovered holds the prior password accepted by CSDD until it is saved in Vault.
+	recovered *recoveredPassword
+
+	// lastPM is the password management flag returned by CSDD on the last login of this instance, -1 if unknown.
+	lastPM atomic.Int32
+	// rotate requests background rotation check to be run as soon as possible.
+	rotate chan struct{}
+	cancel context.CancelFunc
+	done   chan struct{}
 }
 
 func newCsddService(app *core.App, config *Configuration, vault vault.Service) (Service, error) {
+	lock, err := newLocker(config)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := upstream.New(app.AppName, config.Client)
+	if err != nil {
+		return nil, err
+	}
+
 	s := &csddService{
 		app:    app,
 		config: config,
 		vault:  vault,
+		lock:   lock,
+		http:   client,
+		codes:  codes(config),
+		rotate: make(chan struct{}, 1),
 	}
 
+	s.lastPM.Store(-1)
+
+	s.breaker = s.newBreaker()
+
+	s.sessions = newSessionPool(config.SessionPoolSize, config.SessionIdleTimeout, config.SessionMaxAge, s.Login, s.Logout)
+
+	app.AddTask(s)
+
 	return s, nil
 }
 
-func (s *csddService) GetCSDDData(ctx *azugo.Context, code string) (*responses.GetDataResponse, error) {
-	token, err := s.Login(ctx)
-	if err != nil {
-		return nil, err
+// Name returns the name of the CSDD service background task.
+func (s *csddService) Name() string {
+	return "csdd"
+}
+
+// Start CSDD password rotation in background.
+func (s *csddService) Start(_ context.Context) error {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s.cancel = cancel
+	s.done = make(chan struct{})
+
+	go s.runRotation(ctx)
+
+	return nil
+}
+
+// Stop password rotation and logs out from all CSDD sessions.
+func (s *csddService) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+		<-s.done
 	}
-	defer s.Logout(ctx, token)
 
-	response, err := s.GetData(ctx, token, code)
-	if err != nil {
-		return nil, err
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	s.sessions.Close(ctx, s.app.Log())
+}
+
+func (s *csddService) log(ctx context.Context) *zap.Logger {
+	if c, ok := tracing.RequestContext(ctx); ok {
+		return c.Log()
 	}
 
-	return response, nil
+	return s.app.Log()
+}
+
+// startCall starts span for CSDD service call.
+func startCall(ctx context.Context, serviceName string) (context.Context, trace.Span) {
+	return tracing.Start(ctx, "csdd."+serviceName,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("csdd.service_name", serviceName)))
+}
+
+func (s *csddService) GetCSDDData(ctx context.Context, code string) (_ *responses.GetDataResponse, err error) {
+	tctx, span := tracing.Start(ctx, "csdd.GetCSDDData")
+	defer tracing.End(span, &err)
+
+	for {
+		sess, err := s.sessions.Acquire(tctx)
+		if err != nil {
+			return nil, err
+		}
+
+		span.SetAttributes(attribute.Bool("csdd.session.reused", sess.reused))
+
+		response, err := s.GetData(tctx, sess.id, code)
+		if err == nil {
+			s.sessions.Release(tctx, sess)
+
+			return response, nil
+		}
+
+		// only not found data proves that session is still valid, as expiry could be
+		// reported with error code that is not known
+		if errors.Is(err, ErrNotFound) {
+			s.sessions.Release(tctx, sess)
+		} else {
+			s.sessions.Discard(tctx, sess)
+		}
+
+		// reused session could have been expired by CSDD, so retry with another session.
+		// Discarded session is not returned by pool again and new session is never reused,
+		// so number of retries is limited by pool size.
+		if errors.Is(err, ErrSessionExpired) && sess.reused {
+			s.log(tctx).Debug("CSDD session has expired, retrying with another session", zap.Error(err))
+
+			continue
+		}
+
+		return nil, err
+	}
 }
 
-func (s *csddService) Login(ctx *azugo.Context) (string, error) {
+func (s *csddService) Login(ctx context.Context) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "csdd.Login")
+	defer tracing.End(span, &err)
+
 	s.tokenMu.Lock()
 	defer s.tokenMu.Unlock()
 
@@ -69,84 +185,55 @@ func (s *csddService) Login(ctx *azugo.Context) (string, error) {
 		return "", err
 	}
 
+	vaultdata = s.recoveredData(vaultdata)
+
+	if err := s.recoveryBlocked(vaultdata); err != nil {
+		return "", err
+	}
+
 	response, err := s.CallLogin(ctx, vaultdata)
 	if err != nil {
-		ctx.Log().Error("Finish get csdd sessionID with error", zap.Error(err))
+		s.log(ctx).Error("Finish get csdd sessionID with error", zap.Error(err))
 
 		return "", err
 	}
 
-	// if is error in response, then check if error code is F-00011
+	// if is error in response, then check if password is not accepted
 	if len(response.Errors) > 0 {
-		// if error code= F-00011, get one prior password,
-		if response.Errors[0].ClientMessageCode == "F-00011" {
-			vaultdataPriorVersion, err := s.vault.GetCSDDAuthData(ctx, vaultdata.Data.Metadata.Version)
-			if err != nil {
-				return "", err
-			}
-
-			// try login with prior password
-			response, err = s.CallLogin(ctx, vaultdataPriorVersion)
-			// if can not login with prior password, then panic
-			if err != nil {
-				return "", err
-			}
-
-			// if ok login with prior password, then save prior correct password to vault
-			if _, err := s.vault.ChangeVaultData(ctx, vaultdataPriorVersion.Data.Data.Password); err != nil {
-				return "", err
-			}
-
-			// create and save new password to vault and csdd
-			s.ChangePassword(ctx, vaultdataPriorVersion, response.Rowset[0].SessionID)
-
-			// logout from incorrect session
-			// nevaig -> s.Logout(ctx, response.Rowset[0].SessionID)
-
-			// get leatest vault data
-			vaultdata, err = s.vault.GetCSDDAuthData(ctx, 0)
-			if err != nil {
-				return "", err
-			}
-			// call login with new password
-			response, err = s.CallLogin(ctx, vaultdata)
-			if err != nil {
-				return "", err
-			}
-		} else {
-			ctx.Log().Error("Error login to CSDD: " + response.Errors[0].ClientMessageCode + ": " + response.Errors[0].ClientMessage)
+		err = s.newError("Chk_web_Gliet", response.Errors[0].ClientMessageCode, response.Errors[0].ClientMessage)
+		if !errors.Is(err, ErrInvalidPassword) {
+			s.log(ctx).Error("Error login to CSDD", zap.Error(err))
+
+			return "", err
+		}
 
-			return "", errors.New(response.Errors[0].ClientMessageCode + ": " + response.Errors[0].ClientMessage)
+		response, err = s.recoverLogin(ctx, vaultdata)
+		if err != nil {
+			return "", err
 		}
 	}
 
-	// if PM == 1 vai PM == 2, need new password
-	// or if password is older than "s.config.ChangePasswordDays" days
-	// but we can call data by this session
-	Days, _ := strconv.Atoi(s.config.CSDDChangePasswordDays)
-	DurationDays := time.Duration(24*Days) * time.Hour
-
-	if response.Rowset[0].PM == 1 ||
-		response.Rowset[0].PM == 2 ||
-		// ja parole ir vecāka par "s.config.ChangePasswordDays" dienām
-		vaultdata.Data.Metadata.CreatedTime.Add(DurationDays).Before(time.Now()) {
-		s.ChangePassword(ctx, vaultdata, response.Rowset[0].SessionID)
+	s.lastPM.Store(int32(response.Rowset[0].PM))
+
+	// if PM == 1 vai PM == 2, need new password, but we can call data by this session
+	if passwordExpired(response.Rowset[0].PM) {
+		s.RequestRotation()
 	}
 
 	return response.Rowset[0].SessionID, nil
 }
 
-func (s *csddService) CallLogin(ctx *azugo.Context, vaultdata *responses.VaultGetDataResponse) (*responses.LoginResponse, error) {
-	response := &responses.LoginResponse{}
+func (s *csddService) CallLogin(ctx context.Context, vaultdata *responses.VaultGetDataResponse) (_ *responses.LoginResponse, err error) {
+	ctx, span := startCall(ctx, "Chk_web_Gliet")
+	defer tracing.End(span, &err)
 
-	client := ctx.HTTPClient()
+	response := &responses.LoginResponse{}
 
-	if s.config.SkipVerify {
-		client = ctx.HTTPClient().WithOptions(&http.TLSConfig{InsecureSkipVerify: true})
-	}
+	start := time.Now()
 
-	err := client.PostJSON(
-		s.config.CSDDUrl,
+	err = s.post(
+		ctx,
+		"Chk_web_Gliet",
 		struct {
 			SystemGUID  string `json:"SystemGUID"`
 			SystemName  string `json:"SystemName"`
@@ -169,30 +256,41 @@ func (s *csddService) CallLogin(ctx *azugo.Context, vaultdata *responses.VaultGe
 		},
 		response,
 	)
+
+	code := ""
+	if len(response.Errors) > 0 {
+		code = response.Errors[0].ClientMessageCode
+		span.SetAttributes(attribute.String("csdd.client_message_code", code))
+	}
+
+	metrics.ObserveCSDD("Chk_web_Gliet", start, err, code)
+
 	if err != nil {
-		ctx.Log().Error("Finish get csdd sessionID with error", zap.Error(err))
+		s.log(ctx).Error("Finish get csdd sessionID with error", zap.Error(err))
 
-		return nil, err
+		return nil, unavailableError("Chk_web_Gliet", err)
 	}
 
 	return response, nil
 }
 
-func (s *csddService) Logout(ctx *azugo.Context, token string) {
-	client := ctx.HTTPClient()
+func (s *csddService) Logout(ctx context.Context, token string) {
+	var err error
 
-	if s.config.SkipVerify {
-		client = ctx.HTTPClient().WithOptions(&http.TLSConfig{InsecureSkipVerify: true})
-	}
+	ctx, span := startCall(ctx, "Del_Fses")
+	defer tracing.End(span, &err)
+
+	s.log(ctx).Debug("===> start csdd logout")
 
-	ctx.Log().Debug("===> start csdd logout")
+	start := time.Now()
 
-	if err := client.PostJSON(
-		s.config.CSDDUrl,
+	err = s.post(
+		ctx,
+		"Del_Fses",
 		struct {
 			SystemGUID  string `json:"SystemGUID"`
 			SystemName  string `json:"SystemName"`
-			ServiceName string `json:"Del_Fses"`
+			ServiceName string `json:"ServiceName"`
 			SessionID   string `json:"SessionID"`
 		}{
 			SystemGUID:  s.config.CSDDSystemGUID,
@@ -201,25 +299,30 @@ func (s *csddService) Logout(ctx *azugo.Context, token string) {
 			SessionID:   token,
 		},
 		nil,
-	); err != nil {
-		ctx.Log().Error("Finish csdd logout with error", zap.Error(err))
+	)
+
+	metrics.ObserveCSDD("Del_Fses", start, err, "")
+
+	if err != nil {
+		s.log(ctx).Error("Finish csdd logout with error", zap.Error(err))
 	}
 
-	ctx.Log().Debug("===> finish csdd logout")
+	s.log(ctx).Debug("===> finish csdd logout")
 }
 
-func (s *csddService) GetData(ctx *azugo.Context, token string, code string) (*responses.GetDataResponse, error) {
+func (s *csddService) GetData(ctx context.Context, token string, code string) (_ *responses.GetDataResponse, err error) {
+	ctx, span := startCall(ctx, "Qry_va")
+	defer tracing.End(span, &err)
+
 	response := &responses.GetDataResponse{}
-	client := ctx.HTTPClient()
 
-	if s.config.SkipVerify {
-		client = ctx.HTTPClient().WithOptions(&http.TLSConfig{InsecureSkipVerify: true})
-	}
+	s.log(ctx).Debug("===> start get csdd data")
 
-	ctx.Log().Debug("===> start get csdd data")
+	start := time.Now()
 
-	err := client.PostJSON(
-		s.config.CSDDUrl,
+	err = s.post(
+		ctx,
+		"Qry_va",
 		struct {
 			SystemGUID  string `json:"SystemGUID"`
 			SystemName  string `json:"SystemName"`
@@ -247,40 +350,124 @@ func (s *csddService) GetData(ctx *azugo.Context, token string, code string) (*r
 		},
 		response,
 	)
+
+	errCode := ""
+	if len(response.Errors) > 0 {
+		errCode = response.Errors[0].ClientMessageCode
+		span.SetAttributes(attribute.String("csdd.client_message_code", errCode))
+	}
+
+	metrics.ObserveCSDD("Qry_va", start, err, errCode)
+
 	if err != nil {
-		ctx.Log().Error("Finish get csdd data with error", zap.Error(err))
+		s.log(ctx).Error("Finish get csdd data with error", zap.Error(err))
 
-		return nil, err
+		return nil, unavailableError("Qry_va", err)
+	}
+
+	if len(response.Errors) > 0 {
+		return nil, s.newError("Qry_va", errCode, response.Errors[0].ClientMessage)
 	}
 
 	return response, nil
 }
 
-func (s *csddService) ChangePassword(ctx *azugo.Context, indata *responses.VaultGetDataResponse, sessionID string) {
-	// vispirms saglabājam jauno paroli Vault
+// ChangePassword generates new password and saves it to Vault and CSDD.
+//
+// Progress is persisted to Vault secret custom metadata, so that rotation interrupted
+// at any step can be reconciled later. Returns *vault.ConflictError if password in Vault
+// has been changed since indata was read.
+func (s *csddService) ChangePassword(ctx context.Context, indata *responses.VaultGetDataResponse, sessionID string) (_ int, err error) {
+	ctx, span := tracing.Start(ctx, "csdd.ChangePassword")
+	defer tracing.End(span, &err)
+
 	oldPsw := indata.Data.Data.Password
-	newPsw := generateNewPassword()
 
-	res, err := s.vault.ChangeVaultData(ctx, newPsw)
+	newPsw, err := s.config.PasswordPolicy.Generate()
+	if err != nil {
+		return 0, err
+	}
+
+	// never send password that CSDD would reject
+	if err := s.config.PasswordPolicy.Check(newPsw); err != nil {
+		return 0, err
+	}
+
+	r := &rotation{
+		From: indata.Data.Metadata.Version,
+	}
+
+	if err := s.saveRotation(ctx, r, rotationPending); err != nil {
+		return 0, err
+	}
+
+	// vispirms saglabājam jauno paroli Vault
+	res, err := s.vault.ChangeVaultData(ctx, newPsw, r.From)
+	if err != nil {
+		// nothing has been changed
+		r.To = r.From
+		r.Result = rotationResultFailed
+		r.Error = err.Error()
+		_ = s.saveRotation(ctx, r, rotationCommitted)
+
+		return 0, err
+	}
+
+	r.To = res.Data.Version
+	_ = s.saveRotation(ctx, r, rotationPending)
+
 	// if Vault success, call CSDD change password
-	if err == nil && len(res.Errors) == 0 {
-		result, err := s.CallChangePassword(ctx, indata, sessionID, newPsw)
-		// when succes, response ir empty
-
-		// if error when change password in CSDD
-		if err != nil || len(result.Errors) > 0 {
-			ctx.Log().Error("Error changing password in CSDD", zap.Error(err)) // change back to old password in vault
-			_, _ = s.vault.ChangeVaultData(ctx, oldPsw)                        // if error in Vault, then next login is with error "F-00011"
+	result, err := s.CallChangePassword(ctx, indata, sessionID, newPsw)
+	if err != nil {
+		// it is unknown if CSDD has changed the password, so leave rotation
+		// pending to be reconciled by probing both passwords
+		s.log(ctx).Error("Error changing password in CSDD, rotation left pending", zap.Error(err))
+
+		return 0, err
+	}
+
+	// when succes, response ir empty
+	if len(result.Errors) == 0 {
+		if err := s.saveRotation(ctx, r, rotationConfirmed); err != nil {
+			return 0, err
 		}
+
+		r.Result = rotationResultRotated
+
+		return r.To, s.saveRotation(ctx, r, rotationCommitted)
+	}
+
+	err = s.newError("Upd_web_parole", result.Errors[0].ClientMessageCode, result.Errors[0].ClientMessage)
+
+	// CSDD has rejected new password, change back to old password in vault
+	s.log(ctx).Error("Error changing password in CSDD", zap.Error(err))
+
+	// if error in Vault, rotation stays pending and will be reconciled
+	res, verr := s.vault.ChangeVaultData(ctx, oldPsw, r.To)
+	if verr != nil {
+		s.log(ctx).Error("Error restoring old password in Vault", zap.Error(verr))
+
+		return 0, err
 	}
+
+	r.To = res.Data.Version
+	r.Result = rotationResultRolledBack
+	r.Error = err.Error()
+	_ = s.saveRotation(ctx, r, rotationCommitted)
+
+	return 0, err
 }
 
-func (s *csddService) CallChangePassword(ctx *azugo.Context, indata *responses.VaultGetDataResponse, sessionID string, newPsw string) (*responses.ChangePasswordResponse, error) {
+func (s *csddService) CallChangePassword(ctx context.Context, indata *responses.VaultGetDataResponse, sessionID string, newPsw string) (_ *responses.ChangePasswordResponse, err error) {
+	ctx, span := startCall(ctx, "Upd_web_parole")
+	defer tracing.End(span, &err)
+
 	result := &responses.ChangePasswordResponse{}
-	client := ctx.HTTPClient()
+	start := time.Now()
 
-	err := client.PostJSON(
-		s.config.CSDDUrl,
+	err = s.post(
+		ctx,
+		"Upd_web_parole",
 		struct {
 			SystemGUID  string `json:"SystemGUID"`
 			SystemName  string `json:"SystemName"`
@@ -305,47 +492,20 @@ func (s *csddService) CallChangePassword(ctx *azugo.Context, indata *responses.V
 		},
 		result,
 	)
-	if err != nil {
-		ctx.Log().Error("Finish change password with error", zap.Error(err))
-
-		return result, err
-	}
-
-	return result, nil
-}
-
-/**
- * generateNewPassword is a function that generates a new password.
- */
-func generateNewPassword() string {
-	b := make([]byte, 16)
-
-	r := map[int]int{0: 2}
-	for i := range r {
-		num, _ := rand.Int(rand.Reader, big.NewInt(int64(len(charsNumbers))))
-		b[i] = charsNumbers[num.Int64()]
-	}
 
-	for i := 2; i < 5; i++ {
-		num, _ := rand.Int(rand.Reader, big.NewInt(int64(len(charsUppers))))
-		b[i] = charsUppers[num.Int64()]
+	code := ""
+	if len(result.Errors) > 0 {
+		code = result.Errors[0].ClientMessageCode
+		span.SetAttributes(attribute.String("csdd.client_message_code", code))
 	}
 
-	for i := 5; i < 14; i++ {
-		num, _ := rand.Int(rand.Reader, big.NewInt(int64(len(charsLowers))))
-		b[i] = charsLowers[num.Int64()]
-	}
+	metrics.ObserveCSDD("Upd_web_parole", start, err, code)
 
-	for i := 14; i < 16; i++ {
-		num, _ := rand.Int(rand.Reader, big.NewInt(int64(len(charsSpecial))))
-		b[i] = charsSpecial[num.Int64()]
-	}
+	if err != nil {
+		s.log(ctx).Error("Finish change password with error", zap.Error(err))
 
-	for i := len(b) - 1; i > 0; i-- {
-		jBig, _ := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
-		j := int(jBig.Int64())
-		b[i], b[j] = b[j], b[i]
+		return result, unavailableError("Upd_web_parole", err)
 	}
 
-	return string(b)
+	return result, nil
 }
@@ -0,0 +1,153 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package csddmock
+
+import (
+	_ "embed"
+	"encoding/json"
+	"os"
+	"time"
+
+	"git.zzdats.lv/edim/api-mdl/routes/responses"
+)
+
+//go:embed fixtures.json
+var defaultFixtures []byte
+
+// Fixtures describe CSDD users, persons and faults served by the mock.
+type Fixtures struct {
+	// SystemGUID and SystemName are checked if set.
+	SystemGUID string `json:"system_guid"`
+	SystemName string `json:"system_name"`
+	// SessionTTL is the idle time after which session expires, 0 for no expiry.
+	SessionTTL Duration `json:"session_ttl"`
+
+	Users   []*User                           `json:"users"`
+	Persons map[string]*responses.MDLResponse `json:"persons"`
+	Errors  Errors                            `json:"errors"`
+	Faults  []*Fault                          `json:"faults"`
+}
+
+// User is a CSDD technical user.
+type User struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// PM is the password management flag returned on login: 1 or 2 if password must be changed.
+	PM int `json:"pm"`
+}
+
+// Error codes returned by the mock by default.
+const (
+	CodeInvalidPassword = "F-00011"
+	CodeInvalidSession  = "F-00001"
+	CodeAccountLocked   = "F-00012"
+	CodeNotFound        = "F-00404"
+)
+
+// Errors are CSDD errors returned by the mock in regular flow.
+type Errors struct {
+	InvalidPassword *responses.ErrorResponse `json:"invalid_password"`
+	InvalidSession  *responses.ErrorResponse `json:"invalid_session"`
+	NotFound        *responses.ErrorResponse `json:"not_found"`
+}
+
+// Fault is an error injected into responses of matching requests.
+type Fault struct {
+	// Service is the CSDD ServiceName the fault applies to, empty for all services.
+	Service string `json:"service"`
+	// Person is the personal code of Qry_va request the fault applies to, empty for all.
+	Person string `json:"person"`
+	// After is the number of matching requests to pass before fault is applied.
+	After int `json:"after"`
+	// Times is the number of requests fault is applied to, 0 for all.
+	Times int `json:"times"`
+
+	// Delay delays the response.
+	Delay Duration `json:"delay"`
+	// Status is the HTTP status code returned instead of response.
+	Status int `json:"status"`
+	// Error is the CSDD error returned in response.
+	Error *responses.ErrorResponse `json:"error"`
+	// ExpireSession expires the request session before it is handled.
+	ExpireSession bool `json:"expire_session"`
+
+	hits    int
+	applied int
+}
+
+// Duration is time.Duration that is unmarshaled from duration string (e.g. "1.5s").
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(v)
+
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// DefaultFixtures returns fixtures with a test user and persons.
+func DefaultFixtures() *Fixtures {
+	f, err := parseFixtures(defaultFixtures)
+	if err != nil {
+		panic(err)
+	}
+
+	return f
+}
+
+// LoadFixtures reads fixtures from JSON file.
+func LoadFixtures(path string) (*Fixtures, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseFixtures(data)
+}
+
+func parseFixtures(data []byte) (*Fixtures, error) {
+	f := &Fixtures{}
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, err
+	}
+
+	if f.Persons == nil {
+		f.Persons = make(map[string]*responses.MDLResponse)
+	}
+
+	if f.Errors.InvalidPassword == nil {
+		f.Errors.InvalidPassword = &responses.ErrorResponse{
+			ClientMessageCode: CodeInvalidPassword,
+			ClientMessage:     "Nepareizs lietotāja vārds vai parole",
+		}
+	}
+
+	if f.Errors.InvalidSession == nil {
+		f.Errors.InvalidSession = &responses.ErrorResponse{
+			ClientMessageCode: CodeInvalidSession,
+			ClientMessage:     "Sesija nav derīga",
+		}
+	}
+
+	if f.Errors.NotFound == nil {
+		f.Errors.NotFound = &responses.ErrorResponse{
+			ClientMessageCode: CodeNotFound,
+			ClientMessage:     "Dati nav atrasti",
+		}
+	}
+
+	return f, nil
+}
@@ -0,0 +1,361 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+// Package csddmock implements CSDD service stand-in for development and tests.
+package csddmock
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"git.zzdats.lv/edim/api-mdl/routes/responses"
+)
+
+// request is the CSDD service request envelope.
+type request struct {
+	SystemGUID  string          `json:"SystemGUID"`
+	SystemName  string          `json:"SystemName"`
+	ServiceName string          `json:"ServiceName"`
+	SessionID   string          `json:"SessionID"`
+	Params      json.RawMessage `json:"Params"`
+}
+
+type session struct {
+	user     *User
+	lastUsed time.Time
+}
+
+// response is the CSDD service response envelope.
+type response struct {
+	Rowset []any                      `json:"rowset"`
+	Errors []*responses.ErrorResponse `json:"errors"`
+}
+
+// Server is the CSDD mock HTTP handler.
+type Server struct {
+	mu       sync.Mutex
+	fixtures *Fixtures
+	sessions map[string]*session
+	calls    map[string]int
+}
+
+// New returns CSDD mock serving the given fixtures.
+func New(fixtures *Fixtures) *Server {
+	return &Server{
+		fixtures: fixtures,
+		sessions: make(map[string]*session),
+		calls:    make(map[string]int),
+	}
+}
+
+// Password returns the current password of the user.
+func (s *Server) Password(username string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u := s.user(username); u != nil {
+		return u.Password
+	}
+
+	return ""
+}
+
+// SetPassword changes password of the user.
+func (s *Server) SetPassword(username, password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u := s.user(username); u != nil {
+		u.Password = password
+	}
+}
+
+// SetPM changes password management flag returned on login of the user.
+func (s *Server) SetPM(username string, pm int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u := s.user(username); u != nil {
+		u.PM = pm
+	}
+}
+
+// Person returns copy of driving licence data of the person or nil if person is unknown.
+func (s *Server) Person(code string) *responses.MDLResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.fixtures.Persons[code]
+	if !ok {
+		return nil
+	}
+
+	person := *p
+
+	return &person
+}
+
+// SetPerson adds or replaces driving licence data of the person.
+func (s *Server) SetPerson(person *responses.MDLResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.fixtures.Persons[person.PersonalAdministrativeNumber] = person
+}
+
+// AddFault injects fault into responses of matching requests.
+func (s *Server) AddFault(f *Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.fixtures.Faults = append(s.fixtures.Faults, f)
+}
+
+// ClearFaults removes all injected faults.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.fixtures.Faults = nil
+}
+
+// ExpireSessions expires all logged in sessions.
+func (s *Server) ExpireSessions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	clear(s.sessions)
+}
+
+// Sessions returns the number of logged in sessions.
+func (s *Server) Sessions() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.sessions)
+}
+
+// Calls returns the number of requests received for the service.
+func (s *Server) Calls(service string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.calls[service]
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	req := &request{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	if (s.fixtures.SystemGUID != "" && req.SystemGUID != s.fixtures.SystemGUID) ||
+		(s.fixtures.SystemName != "" && req.SystemName != s.fixtures.SystemName) {
+		http.Error(w, "unknown system", http.StatusForbidden)
+
+		return
+	}
+
+	fault := s.fault(req)
+	if fault != nil && fault.Delay > 0 {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(time.Duration(fault.Delay)):
+		}
+	}
+
+	if fault != nil && fault.Status != 0 {
+		http.Error(w, http.StatusText(fault.Status), fault.Status)
+
+		return
+	}
+
+	var resp *response
+
+	if fault != nil && fault.Error != nil {
+		resp = errorResponse(fault.Error)
+	} else {
+		resp = s.handle(req)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// fault returns fault to be applied to the request, if any.
+func (s *Server) fault(req *request) *Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls[req.ServiceName]++
+
+	person := ""
+	if req.ServiceName == "Qry_va" {
+		params := &struct {
+			Pk string `json:"pk"`
+		}{}
+		_ = json.Unmarshal(req.Params, params)
+		person = params.Pk
+	}
+
+	for _, f := range s.fixtures.Faults {
+		if (f.Service != "" && f.Service != req.ServiceName) || (f.Person != "" && f.Person != person) {
+			continue
+		}
+
+		f.hits++
+		if f.hits <= f.After || (f.Times > 0 && f.applied >= f.Times) {
+			continue
+		}
+
+		f.applied++
+
+		if f.ExpireSession {
+			delete(s.sessions, req.SessionID)
+		}
+
+		return f
+	}
+
+	return nil
+}
+
+func (s *Server) handle(req *request) *response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch req.ServiceName {
+	case "Chk_web_Gliet":
+		return s.login(req)
+	case "Del_Fses":
+		delete(s.sessions, req.SessionID)
+
+		return rowsResponse()
+	}
+
+	sess := s.session(req.SessionID)
+	if sess == nil {
+		return errorResponse(s.fixtures.Errors.InvalidSession)
+	}
+
+	switch req.ServiceName {
+	case "Qry_va":
+		params := &struct {
+			Pk string `json:"pk"`
+		}{}
+		_ = json.Unmarshal(req.Params, params)
+
+		person, ok := s.fixtures.Persons[params.Pk]
+		if !ok {
+			return errorResponse(s.fixtures.Errors.NotFound)
+		}
+
+		return rowsResponse(person)
+	case "Upd_web_parole":
+		params := &struct {
+			IeprParole string `json:"iepr_parole"`
+			Parole     string `json:"parole"`
+		}{}
+		_ = json.Unmarshal(req.Params, params)
+
+		if params.IeprParole != sess.user.Password {
+			return errorResponse(s.fixtures.Errors.InvalidPassword)
+		}
+
+		sess.user.Password = params.Parole
+		sess.user.PM = 0
+
+		return rowsResponse()
+	}
+
+	return errorResponse(&responses.ErrorResponse{
+		ClientMessageCode: "F-00000",
+		ClientMessage:     "Nezināms serviss " + req.ServiceName,
+	})
+}
+
+func (s *Server) login(req *request) *response {
+	params := &struct {
+		LietVards string `json:"liet_vards"`
+		Parole    string `json:"parole"`
+	}{}
+	_ = json.Unmarshal(req.Params, params)
+
+	u := s.user(params.LietVards)
+	if u == nil || u.Password != params.Parole {
+		return errorResponse(s.fixtures.Errors.InvalidPassword)
+	}
+
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	id := hex.EncodeToString(b)
+
+	s.sessions[id] = &session{
+		user:     u,
+		lastUsed: time.Now(),
+	}
+
+	return rowsResponse(map[string]any{
+		"sessionid": id,
+		"pm":        u.PM,
+		"fpda":      false,
+		"amats":     "",
+		"kiest_id":  "",
+		"kiest_nos": "",
+		"kt_kodi":   []any{},
+		"kiest":     []any{},
+	})
+}
+
+func (s *Server) session(id string) *session {
+	sess, ok := s.sessions[id]
+	if !ok {
+		return nil
+	}
+
+	if ttl := time.Duration(s.fixtures.SessionTTL); ttl > 0 && time.Since(sess.lastUsed) > ttl {
+		delete(s.sessions, id)
+
+		return nil
+	}
+
+	sess.lastUsed = time.Now()
+
+	return sess
+}
+
+func (s *Server) user(username string) *User {
+	for _, u := range s.fixtures.Users {
+		if u.Username == username {
+			return u
+		}
+	}
+
+	return nil
+}
+
+func rowsResponse(rows ...any) *response {
+	return &response{
+		Rowset: append([]any{}, rows...),
+		Errors: []*responses.ErrorResponse{},
+	}
+}
+
+func errorResponse(e *responses.ErrorResponse) *response {
+	return &response{
+		Rowset: []any{},
+		Errors: []*responses.ErrorResponse{e},
+	}
+}
@@ -0,0 +1,132 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package csdd
+
+import (
+	"errors"
+)
+
+// Error classes that callers can check with errors.Is.
+var (
+	// ErrNotFound is returned when CSDD does not have driving licence data about the person.
+	ErrNotFound = errors.New("driving licence data not found")
+	// ErrSessionExpired is returned when CSDD session is not valid anymore.
+	ErrSessionExpired = errors.New("CSDD session expired")
+	// ErrInvalidPassword is returned when CSDD does not accept service password.
+	ErrInvalidPassword = errors.New("CSDD password is not accepted")
+	// ErrAccountLocked is returned when CSDD service account is locked.
+	ErrAccountLocked = errors.New("CSDD account is locked")
+	// ErrUnavailable is returned when CSDD can not be reached or responds with HTTP error.
+	ErrUnavailable = errors.New("CSDD is unavailable")
+	// ErrCircuitOpen is returned without calling CSDD after repeated failures to reach CSDD.
+	ErrCircuitOpen = errors.New("CSDD circuit breaker is open")
+	// ErrUnknown is returned for CSDD error codes that are not in the catalogue or configured.
+	ErrUnknown = errors.New("CSDD error")
+)
+
+// codeInfo describes known CSDD error code.
+type codeInfo struct {
+	// Class is the error class reported to callers.
+	Class error
+	// Retryable is true if the same request can succeed when repeated.
+	Retryable bool
+}
+
+// catalogue of known CSDD clientMessageCode values.
+//
+// Additional codes of not found data and expired session can be configured
+// with CSDD_NOT_FOUND_CODES and CSDD_SESSION_EXPIRED_CODES.
+var catalogue = map[string]codeInfo{
+	"F-00001": {Class: ErrSessionExpired, Retryable: true},
+	"F-00011": {Class: ErrInvalidPassword},
+	"F-00012": {Class: ErrAccountLocked},
+	"F-00404": {Class: ErrNotFound},
+}
+
+// codes returns catalogue of known CSDD codes extended with configured codes.
+func codes(config *Configuration) map[string]codeInfo {
+	c := make(map[string]codeInfo, len(catalogue)+len(config.NotFoundCodes)+len(config.SessionExpiredCodes))
+
+	for code, info := range catalogue {
+		c[code] = info
+	}
+
+	for _, code := range config.NotFoundCodes {
+		c[code] = codeInfo{Class: ErrNotFound}
+	}
+
+	for _, code := range config.SessionExpiredCodes {
+		c[code] = codeInfo{Class: ErrSessionExpired, Retryable: true}
+	}
+
+	return c
+}
+
+// Error is the error returned by CSDD service or when CSDD service call fails.
+type Error struct {
+	// Service is the CSDD ServiceName that returned the error.
+	Service string
+	// Code is the CSDD clientMessageCode, empty if CSDD did not respond.
+	Code string
+	// Message is the CSDD clientMessage.
+	Message string
+	// Class is one of ErrNotFound, ErrSessionExpired, ErrInvalidPassword, ErrAccountLocked, ErrUnavailable or ErrUnknown.
+	Class error
+	// Retryable is true if the same request can succeed when repeated.
+	Retryable bool
+	// Err is the underlying error if CSDD did not respond.
+	Err error
+}
+
+// newError returns error for the CSDD error code classified by the catalogue and configured codes.
+func (s *csddService) newError(service, code, message string) *Error {
+	info, ok := s.codes[code]
+	if !ok {
+		info = codeInfo{Class: ErrUnknown}
+	}
+
+	return &Error{
+		Service:   service,
+		Code:      code,
+		Message:   message,
+		Class:     info.Class,
+		Retryable: info.Retryable,
+	}
+}
+
+// unavailableError returns error for failed CSDD service call.
+func unavailableError(service string, err error) *Error {
+	return &Error{
+		Service:   service,
+		Class:     ErrUnavailable,
+		Retryable: true,
+		Err:       err,
+	}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+
+	return e.Code + ": " + e.Message
+}
+
+// Unwrap allows to match error class and underlying error with errors.Is and errors.As.
+func (e *Error) Unwrap() []error {
+	if e.Err != nil {
+		return []error{e.Class, e.Err}
+	}
+
+	return []error{e.Class}
+}
+
+// IsRetryable returns true if err is CSDD error that can succeed when request is repeated.
+func IsRetryable(err error) bool {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Retryable
+	}
+
+	return false
+}
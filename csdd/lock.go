@@ -0,0 +1,156 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package csdd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// rotationLockKey is the Redis key used for cluster-wide password rotation lock.
+const rotationLockKey = "api-mdl:csdd:rotation-lock"
+
+// ErrLockLost is returned when rotation lock has expired or has been taken over while work was in progress.
+var ErrLockLost = errors.New("CSDD password rotation lock has been lost")
+
+// unlockScript releases the lock only if it is still owned by the caller.
+var unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// extendScript extends the lock TTL only if it is still owned by the caller.
+var extendScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// locker is a cluster-wide lock that guards changes of CSDD password.
+type locker interface {
+	// Lock waits until lock is acquired and returns function to release it.
+	//
+	// Work under the lock must use the returned context, that is canceled with ErrLockLost
+	// as the cause if the lock is lost before it is released.
+	Lock(ctx context.Context) (context.Context, func(), error)
+}
+
+func newLocker(config *Configuration) (locker, error) {
+	if config.RotationLockRedisURL == "" {
+		return noopLocker{}, nil
+	}
+
+	opts, err := redis.ParseURL(config.RotationLockRedisURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &redisLocker{
+		client: redis.NewClient(opts),
+		ttl:    config.RotationLockTTL,
+	}, nil
+}
+
+// noopLocker is used when service is running as a single instance.
+type noopLocker struct{}
+
+func (noopLocker) Lock(ctx context.Context) (context.Context, func(), error) {
+	return ctx, func() {}, nil
+}
+
+type redisLocker struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+func (l *redisLocker) Lock(ctx context.Context) (context.Context, func(), error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, nil, err
+	}
+
+	token := hex.EncodeToString(b)
+
+	for {
+		ok, err := l.client.SetNX(ctx, rotationLockKey, token, l.ttl).Result()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if ok {
+			lctx, cancel := context.WithCancelCause(ctx)
+			done := make(chan struct{})
+
+			go l.renew(lctx, cancel, token, done)
+
+			return lctx, func() {
+				cancel(nil)
+				<-done
+
+				// lock must be released even if request context has been already canceled
+				ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+				defer cancel()
+
+				_ = unlockScript.Run(ctx, l.client, []string{rotationLockKey}, token).Err()
+			}, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, nil, errors.Join(errors.New("timeout waiting for CSDD password rotation lock"), ctx.Err())
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+// renew extends the lock TTL while it is held and cancels the context with ErrLockLost
+// if the lock has been taken over or could not be extended before it expired.
+func (l *redisLocker) renew(ctx context.Context, cancel context.CancelCauseFunc, token string, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(max(l.ttl/3, time.Millisecond))
+	defer ticker.Stop()
+
+	renewed := time.Now()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		ok, err := extendScript.Run(ctx, l.client, []string{rotationLockKey}, token, l.ttl.Milliseconds()).Bool()
+		if err == nil && !ok {
+			cancel(ErrLockLost)
+
+			return
+		}
+
+		if err == nil {
+			renewed = time.Now()
+		} else if time.Since(renewed) >= l.ttl {
+			// Redis has been unavailable for too long and the lock could have expired
+			cancel(ErrLockLost)
+
+			return
+		}
+	}
+}
+
+// lockError returns ErrLockLost instead of the context cancellation error if the lock has been lost.
+func lockError(ctx context.Context, err error) error {
+	if err != nil && errors.Is(context.Cause(ctx), ErrLockLost) {
+		return ErrLockLost
+	}
+
+	return err
+}
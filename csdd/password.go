@@ -0,0 +1,253 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package csdd
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+
+	"azugo.io/core/validation"
+	"github.com/spf13/viper"
+)
+
+const (
+	charsNumbers = "0123456789"
+	charsUppers  = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	charsLowers  = "abcdefghijklmnopqrstuvwxyz"
+	// charsSpecial are the special characters accepted by CSDD.
+	charsSpecial = "~!@-#$+?"
+)
+
+// Password character classes.
+const (
+	ClassDigits  = "digits"
+	ClassUppers  = "uppers"
+	ClassLowers  = "lowers"
+	ClassSpecial = "special"
+)
+
+// maxGenerateAttempts is the number of times password is regenerated if it does not satisfy the policy.
+const maxGenerateAttempts = 100
+
+// PasswordPolicy defines requirements for generated CSDD passwords.
+type PasswordPolicy struct {
+	// Length is the length of the password.
+	Length int `mapstructure:"length" validate:"min=8,max=128"`
+	// RequiredClasses are character classes that password must contain.
+	RequiredClasses []string `mapstructure:"required_classes" validate:"min=1,dive,oneof=digits uppers lowers special"`
+	// MinDigits is the minimum number of digits.
+	MinDigits int `mapstructure:"min_digits" validate:"min=0"`
+	// MinUppers is the minimum number of uppercase letters.
+	MinUppers int `mapstructure:"min_uppers" validate:"min=0"`
+	// MinLowers is the minimum number of lowercase letters.
+	MinLowers int `mapstructure:"min_lowers" validate:"min=0"`
+	// MinSpecial is the minimum number of special characters.
+	MinSpecial int `mapstructure:"min_special" validate:"min=0"`
+	// SpecialChars are the allowed special characters, must be a subset of characters accepted by CSDD.
+	SpecialChars string `mapstructure:"special_chars"`
+	// MaxRepeat is the maximum number of identical consecutive characters, 0 means no limit.
+	MaxRepeat int `mapstructure:"max_repeat" validate:"min=0"`
+}
+
+func (c *PasswordPolicy) Bind(prefix string, v *viper.Viper) {
+	v.SetDefault(prefix+".length", 16)
+	v.SetDefault(prefix+".required_classes", []string{ClassDigits, ClassUppers, ClassLowers, ClassSpecial})
+	v.SetDefault(prefix+".min_digits", 2)
+	v.SetDefault(prefix+".min_uppers", 3)
+	v.SetDefault(prefix+".min_lowers", 9)
+	v.SetDefault(prefix+".min_special", 2)
+	v.SetDefault(prefix+".special_chars", charsSpecial)
+	v.SetDefault(prefix+".max_repeat", 2)
+
+	_ = v.BindEnv(prefix+".length", "CSDD_PASSWORD_LENGTH")
+	_ = v.BindEnv(prefix+".required_classes", "CSDD_PASSWORD_REQUIRED_CLASSES")
+	_ = v.BindEnv(prefix+".min_digits", "CSDD_PASSWORD_MIN_DIGITS")
+	_ = v.BindEnv(prefix+".min_uppers", "CSDD_PASSWORD_MIN_UPPERS")
+	_ = v.BindEnv(prefix+".min_lowers", "CSDD_PASSWORD_MIN_LOWERS")
+	_ = v.BindEnv(prefix+".min_special", "CSDD_PASSWORD_MIN_SPECIAL")
+	_ = v.BindEnv(prefix+".special_chars", "CSDD_PASSWORD_SPECIAL_CHARS")
+	_ = v.BindEnv(prefix+".max_repeat", "CSDD_PASSWORD_MAX_REPEAT")
+}
+
+// Validate password policy configuration.
+func (c *PasswordPolicy) Validate(valid *validation.Validate) error {
+	if err := valid.Struct(c); err != nil {
+		return err
+	}
+
+	for _, ch := range c.SpecialChars {
+		if !strings.ContainsRune(charsSpecial, ch) {
+			return fmt.Errorf("password policy special character %q is not accepted by CSDD, allowed are %q", ch, charsSpecial)
+		}
+	}
+
+	for _, class := range []string{ClassDigits, ClassUppers, ClassLowers, ClassSpecial} {
+		if c.min(class) > 0 && !slices.Contains(c.RequiredClasses, class) {
+			return fmt.Errorf("password policy sets minimum number of %s to %d but %s is not a required class", class, c.min(class), class)
+		}
+	}
+
+	minTotal := 0
+
+	for _, class := range c.RequiredClasses {
+		if c.chars(class) == "" {
+			return fmt.Errorf("password policy requires %s but no characters are allowed", class)
+		}
+
+		minTotal += max(c.min(class), 1)
+	}
+
+	if minTotal > c.Length {
+		return fmt.Errorf("password policy minimum character counts exceed password length %d", c.Length)
+	}
+
+	return nil
+}
+
+// Generate returns new random password that satisfies the policy.
+func (c *PasswordPolicy) Generate() (string, error) {
+	for range maxGenerateAttempts {
+		b := make([]byte, 0, c.Length)
+
+		for _, class := range c.RequiredClasses {
+			chars, err := randomChars(c.chars(class), max(c.min(class), 1))
+			if err != nil {
+				return "", err
+			}
+
+			b = append(b, chars...)
+		}
+
+		rest, err := randomChars(c.allowed(), c.Length-len(b))
+		if err != nil {
+			return "", err
+		}
+
+		b = append(b, rest...)
+
+		for i := len(b) - 1; i > 0; i-- {
+			jBig, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+			if err != nil {
+				return "", err
+			}
+
+			j := int(jBig.Int64())
+			b[i], b[j] = b[j], b[i]
+		}
+
+		if c.Check(string(b)) == nil {
+			return string(b), nil
+		}
+	}
+
+	return "", errors.New("failed to generate password that satisfies password policy")
+}
+
+// Check returns error if password does not satisfy the policy.
+func (c *PasswordPolicy) Check(password string) error {
+	if len(password) != c.Length {
+		return fmt.Errorf("password length must be %d characters", c.Length)
+	}
+
+	allowed := c.allowed()
+
+	counts := make(map[string]int, len(c.RequiredClasses))
+
+	repeat := 0
+
+	for i := range len(password) {
+		ch := password[i]
+
+		if !strings.ContainsRune(allowed, rune(ch)) {
+			return errors.New("password contains character that is not allowed")
+		}
+
+		for _, class := range c.RequiredClasses {
+			if strings.IndexByte(c.chars(class), ch) >= 0 {
+				counts[class]++
+			}
+		}
+
+		if i > 0 && password[i-1] == ch {
+			repeat++
+		} else {
+			repeat = 1
+		}
+
+		if c.MaxRepeat > 0 && repeat > c.MaxRepeat {
+			return fmt.Errorf("password must not contain more than %d identical consecutive characters", c.MaxRepeat)
+		}
+	}
+
+	for _, class := range c.RequiredClasses {
+		if counts[class] < max(c.min(class), 1) {
+			return fmt.Errorf("password must contain at least %d %s", max(c.min(class), 1), class)
+		}
+	}
+
+	return nil
+}
+
+// chars returns characters of the class.
+func (c *PasswordPolicy) chars(class string) string {
+	switch class {
+	case ClassDigits:
+		return charsNumbers
+	case ClassUppers:
+		return charsUppers
+	case ClassLowers:
+		return charsLowers
+	case ClassSpecial:
+		return c.SpecialChars
+	default:
+		return ""
+	}
+}
+
+// min returns minimum number of characters of the class.
+func (c *PasswordPolicy) min(class string) int {
+	switch class {
+	case ClassDigits:
+		return c.MinDigits
+	case ClassUppers:
+		return c.MinUppers
+	case ClassLowers:
+		return c.MinLowers
+	case ClassSpecial:
+		return c.MinSpecial
+	default:
+		return 0
+	}
+}
+
+// allowed returns all characters allowed in password.
+func (c *PasswordPolicy) allowed() string {
+	var sb strings.Builder
+
+	for _, class := range []string{ClassDigits, ClassUppers, ClassLowers, ClassSpecial} {
+		if slices.Contains(c.RequiredClasses, class) {
+			sb.WriteString(c.chars(class))
+		}
+	}
+
+	return sb.String()
+}
+
+func randomChars(chars string, n int) ([]byte, error) {
+	b := make([]byte, n)
+
+	for i := range b {
+		num, err := rand.Int(rand.Reader, big.NewInt(int64(len(chars))))
+		if err != nil {
+			return nil, err
+		}
+
+		b[i] = chars[num.Int64()]
+	}
+
+	return b, nil
+}
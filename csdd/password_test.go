@@ -0,0 +1,122 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package csdd
+
+import "testing"
+
+func defaultPasswordPolicy() *PasswordPolicy {
+	return &PasswordPolicy{
+		Length:          16,
+		RequiredClasses: []string{ClassDigits, ClassUppers, ClassLowers, ClassSpecial},
+		MinDigits:       2,
+		MinUppers:       3,
+		MinLowers:       9,
+		MinSpecial:      2,
+		SpecialChars:    charsSpecial,
+		MaxRepeat:       2,
+	}
+}
+
+func TestPasswordPolicyGenerate(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy *PasswordPolicy
+	}{
+		{
+			name:   "default",
+			policy: defaultPasswordPolicy(),
+		},
+		{
+			name: "without special characters",
+			policy: &PasswordPolicy{
+				Length:          12,
+				RequiredClasses: []string{ClassDigits, ClassUppers, ClassLowers},
+				MaxRepeat:       1,
+			},
+		},
+		{
+			name: "restricted special characters",
+			policy: &PasswordPolicy{
+				Length:          20,
+				RequiredClasses: []string{ClassDigits, ClassLowers, ClassSpecial},
+				MinDigits:       4,
+				MinSpecial:      4,
+				SpecialChars:    "-+",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for range 100 {
+				psw, err := tt.policy.Generate()
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if len(psw) != tt.policy.Length {
+					t.Fatalf("expected password length %d, got %d", tt.policy.Length, len(psw))
+				}
+
+				if err := tt.policy.Check(psw); err != nil {
+					t.Fatalf("generated password %q does not satisfy policy: %v", psw, err)
+				}
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		err      string
+	}{
+		{
+			name:     "valid",
+			password: "A1~dBefg2hC!ijkm",
+		},
+		{
+			name:     "too short",
+			password: "A1~dBefg2hC!ijk",
+			err:      "password length must be 16 characters",
+		},
+		{
+			name:     "not enough digits",
+			password: "A1~dBefgxhC!ijkm",
+			err:      "password must contain at least 2 digits",
+		},
+		{
+			name:     "not enough special characters",
+			password: "A1~dBefg2hCxijkm",
+			err:      "password must contain at least 2 special",
+		},
+		{
+			name:     "character not accepted by CSDD",
+			password: "A1~dBefg2hC%ijkm",
+			err:      "password contains character that is not allowed",
+		},
+		{
+			name:     "too many identical consecutive characters",
+			password: "A1~dBeee2hC!ijkm",
+			err:      "password must not contain more than 2 identical consecutive characters",
+		},
+	}
+
+	policy := defaultPasswordPolicy()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Check(tt.password)
+
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("expected password to satisfy policy, got %v", err)
+			case tt.err != "" && err == nil:
+				t.Errorf("expected error %q, got nil", tt.err)
+			case tt.err != "" && err.Error() != tt.err:
+				t.Errorf("expected error %q, got %q", tt.err, err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,327 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package csdd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"git.zzdats.lv/edim/api-mdl/metrics"
+	"git.zzdats.lv/edim/api-mdl/routes/responses"
+	"git.zzdats.lv/edim/api-mdl/tracing"
+	"git.zzdats.lv/edim/api-mdl/vault"
+
+	"go.uber.org/zap"
+)
+
+// Password recovery results.
+const (
+	recoveryResultLatest = "latest"
+	recoveryResultPrior  = "prior"
+	recoveryResultFailed = "failed"
+)
+
+// recoveredPassword is the prior password accepted by CSDD that is not saved in Vault yet.
+type recoveredPassword struct {
+	vaultdata *responses.VaultGetDataResponse
+	// latest is the Vault secret version that CSDD did not accept and recovered password must replace.
+	latest int
+}
+
+// recoveryFailure is the state of failed login recovery.
+type recoveryFailure struct {
+	// version is the Vault secret version that CSDD did not accept.
+	version int
+	until   time.Time
+}
+
+// err returns error if login is still suspended after failed recovery.
+func (f *recoveryFailure) err() error {
+	if f == nil || time.Now().After(f.until) {
+		return nil
+	}
+
+	return fmt.Errorf("CSDD login is suspended until %s after failed password recovery", f.until.Format(time.RFC3339))
+}
+
+// recoveryBlocked returns error if login recovery has recently failed and Vault secret has not been changed since,
+// as every further login attempt with wrong password brings CSDD account closer to being locked.
+//
+// Recovery state persisted in Vault secret custom metadata is checked as well, as recovery could have failed
+// on other instance.
+func (s *csddService) recoveryBlocked(vaultdata *responses.VaultGetDataResponse) error {
+	s.statusMu.Lock()
+	f := s.recoveryFailed
+	s.statusMu.Unlock()
+
+	for _, f := range []*recoveryFailure{f, parseRecoveryFailure(vaultdata.Data.Metadata.CustomMetadata)} {
+		if f == nil || f.version != vaultdata.Data.Metadata.Version {
+			continue
+		}
+
+		if err := f.err(); err != nil {
+			return &Error{
+				Service: "Chk_web_Gliet",
+				Class:   ErrInvalidPassword,
+				Err:     err,
+			}
+		}
+	}
+
+	return nil
+}
+
+// setRecoveryFailed sets or clears the state of failed login recovery.
+//
+// State is persisted in Vault secret custom metadata by the rotation in background to be reported by all instances.
+func (s *csddService) setRecoveryFailed(f *recoveryFailure) {
+	s.statusMu.Lock()
+	s.recoveryFailed = f
+	s.recoveryUnsaved = true
+	s.statusMu.Unlock()
+
+	s.RequestRotation()
+}
+
+// setRecovered sets the prior password accepted by CSDD to be used instead of the latest Vault secret version
+// until it is saved in Vault by the rotation in background.
+func (s *csddService) setRecovered(r *recoveredPassword) {
+	s.statusMu.Lock()
+	s.recovered = r
+	s.statusMu.Unlock()
+
+	if r != nil {
+		s.RequestRotation()
+	}
+}
+
+// recoveredData returns the recovered prior password if it has not been saved yet
+// and Vault secret has not been changed since.
+func (s *csddService) recoveredData(vaultdata *responses.VaultGetDataResponse) *responses.VaultGetDataResponse {
+	s.statusMu.Lock()
+	r := s.recovered
+	s.statusMu.Unlock()
+
+	if r == nil || r.latest != vaultdata.Data.Metadata.Version {
+		return vaultdata
+	}
+
+	return r.vaultdata
+}
+
+// recoveryPending returns true if recovered password or the state of login recovery has not been saved in Vault yet.
+func (s *csddService) recoveryPending() bool {
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+
+	return s.recovered != nil || s.recoveryUnsaved
+}
+
+// saveRecovery saves recovered password as the latest Vault secret version
+// and the state of login recovery in Vault secret custom metadata.
+//
+// Login recovery does not write to Vault itself, so that requests are not delayed or failed by Vault writes.
+//
+// Must be called holding both token mutex and the rotation lock.
+func (s *csddService) saveRecovery(ctx context.Context) error {
+	s.statusMu.Lock()
+	r, f, unsaved := s.recovered, s.recoveryFailed, s.recoveryUnsaved
+	s.recoveryUnsaved = false
+	s.statusMu.Unlock()
+
+	if unsaved {
+		metadata := map[string]string{
+			metadataSuspendedVersion: "",
+			metadataSuspendedUntil:   "",
+		}
+
+		if f != nil {
+			metadata[metadataSuspendedVersion] = strconv.Itoa(f.version)
+			metadata[metadataSuspendedUntil] = f.until.UTC().Format(time.RFC3339)
+		}
+
+		if err := s.updateMetadata(ctx, metadata); err != nil {
+			s.statusMu.Lock()
+			s.recoveryUnsaved = true
+			s.statusMu.Unlock()
+
+			return fmt.Errorf("failed to save CSDD login recovery state: %w", err)
+		}
+	}
+
+	if r == nil {
+		return nil
+	}
+
+	_, err := s.vault.ChangeVaultData(ctx, r.vaultdata.Data.Data.Password, r.latest)
+
+	var conflict *vault.ConflictError
+	if err != nil && !errors.As(err, &conflict) {
+		return err
+	}
+
+	// recovered password is no longer needed if Vault secret has been changed by someone else
+	if err != nil {
+		s.log(ctx).Warn("CSDD password in Vault has been changed after recovery", zap.Error(err))
+	}
+
+	s.setRecovered(nil)
+
+	return nil
+}
+
+// parseRecoveryFailure returns the state of failed login recovery persisted in Vault secret custom metadata.
+func parseRecoveryFailure(metadata map[string]string) *recoveryFailure {
+	version, err := strconv.Atoi(metadata[metadataSuspendedVersion])
+	if err != nil {
+		return nil
+	}
+
+	until, err := time.Parse(time.RFC3339, metadata[metadataSuspendedUntil])
+	if err != nil {
+		return nil
+	}
+
+	return &recoveryFailure{
+		version: version,
+		until:   until,
+	}
+}
+
+// recoverLogin is called when CSDD does not accept password from Vault (ErrInvalidPassword).
+//
+// It walks back through prior Vault secret versions until CSDD accepts one of the passwords,
+// but stops after the configured number of failed login attempts to avoid CSDD account lockout.
+// Recovered password and the state of recovery are saved in Vault by the rotation in background.
+func (s *csddService) recoverLogin(ctx context.Context, vaultdata *responses.VaultGetDataResponse) (_ *responses.LoginResponse, err error) {
+	ctx, span := tracing.Start(ctx, "csdd.recoverLogin")
+	defer tracing.End(span, &err)
+
+	// password could have been just changed by other instance, so wait for it to finish
+	lctx, unlock, err := s.lock.Lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	ctx = lctx
+	defer func() { err = lockError(ctx, err) }()
+
+	// recovered password that has not been saved yet is not accepted anymore
+	s.setRecovered(nil)
+
+	// failed login with the latest password
+	attempts := 1
+	tried := map[string]bool{
+		vaultdata.Data.Data.Password: true,
+	}
+
+	// try again with the latest password if it has changed in the meantime
+	latest, err := s.vault.GetCSDDAuthData(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// recovery could have already failed on other instance while waiting for the lock
+	if err := s.recoveryBlocked(latest); err != nil {
+		return nil, err
+	}
+
+	candidates := []*responses.VaultGetDataResponse{latest}
+
+	versions, err := s.vault.GetCSDDVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	walked := 0
+
+	for _, version := range versions {
+		if version >= vaultdata.Data.Metadata.Version {
+			continue
+		}
+
+		if walked >= s.config.RecoveryMaxVersions {
+			break
+		}
+
+		walked++
+
+		prior, err := s.vault.GetCSDDAuthData(ctx, version)
+		if err != nil {
+			return nil, err
+		}
+
+		candidates = append(candidates, prior)
+	}
+
+	for _, candidate := range candidates {
+		if tried[candidate.Data.Data.Password] {
+			continue
+		}
+
+		if attempts >= s.config.MaxLoginAttempts {
+			s.log(ctx).Warn("Stopping CSDD password recovery to avoid account lockout", zap.Int("attempts", attempts))
+
+			break
+		}
+
+		tried[candidate.Data.Data.Password] = true
+		attempts++
+
+		response, err := s.CallLogin(ctx, candidate)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(response.Errors) > 0 {
+			err := s.newError("Chk_web_Gliet", response.Errors[0].ClientMessageCode, response.Errors[0].ClientMessage)
+			if errors.Is(err, ErrInvalidPassword) {
+				continue
+			}
+
+			// further attempts would not succeed, e.g. account is locked
+			return nil, err
+		}
+
+		s.setRecoveryFailed(nil)
+
+		if candidate == latest {
+			metrics.ObservePasswordRecovery(recoveryResultLatest)
+
+			return response, nil
+		}
+
+		s.log(ctx).Warn("Recovered CSDD password from prior Vault version",
+			zap.Int("version", candidate.Data.Metadata.Version),
+			zap.Int("latest_version", latest.Data.Metadata.Version))
+
+		// prior correct password is saved to Vault by rotation in background
+		s.setRecovered(&recoveredPassword{
+			vaultdata: candidate,
+			latest:    latest.Data.Metadata.Version,
+		})
+
+		metrics.ObservePasswordRecovery(recoveryResultPrior)
+
+		return response, nil
+	}
+
+	s.setRecoveryFailed(&recoveryFailure{
+		version: latest.Data.Metadata.Version,
+		until:   time.Now().Add(s.config.RecoveryBackoff),
+	})
+
+	metrics.ObservePasswordRecovery(recoveryResultFailed)
+
+	s.log(ctx).Error("CSDD password recovery failed", zap.Int("attempts", attempts), zap.Int("versions", walked))
+
+	return nil, &Error{
+		Service: "Chk_web_Gliet",
+		Class:   ErrInvalidPassword,
+		Err:     errors.New("CSDD does not accept any known password"),
+	}
+}
@@ -0,0 +1,153 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package csdd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"time"
+
+	"git.zzdats.lv/edim/api-mdl/metrics"
+	"git.zzdats.lv/edim/api-mdl/upstream"
+
+	"github.com/sony/gobreaker/v2"
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// retryPolicy is the retry policy of CSDD service call.
+type retryPolicy struct {
+	attempts int
+	initial  time.Duration
+	max      time.Duration
+	// unsent allows retry only if request has not been processed by CSDD.
+	unsent bool
+}
+
+// retryPolicy returns retry policy of the CSDD service.
+//
+// Only idempotent services are retried. Upd_web_parole is never retried as it is unknown
+// if CSDD has changed the password when the response is lost. Chk_web_Gliet is retried only
+// if request has not been processed by CSDD as otherwise the created session would be leaked.
+func (s *csddService) retryPolicy(serviceName string) retryPolicy {
+	p := retryPolicy{
+		attempts: 1,
+		initial:  s.config.RetryInitialBackoff,
+		max:      s.config.RetryMaxBackoff,
+	}
+
+	switch serviceName {
+	case "Chk_web_Gliet":
+		p.attempts = s.config.LoginRetryAttempts
+		p.unsent = true
+	case "Qry_va":
+		p.attempts = s.config.QueryRetryAttempts
+	}
+
+	return p
+}
+
+// backoff returns delay before the retry using exponential backoff with full jitter.
+func (p retryPolicy) backoff(retry int) time.Duration {
+	d := p.max
+	if retry < 32 && p.initial<<retry > 0 && p.initial<<retry < p.max {
+		d = p.initial << retry
+	}
+
+	if d <= 0 {
+		return 0
+	}
+
+	return rand.N(d) + 1
+}
+
+// retryable reports if failed call can be retried according to the policy.
+//
+// Transport errors and 5xx responses are retried, invalid responses and 4xx responses are not.
+func (p retryPolicy) retryable(err error) bool {
+	var se *upstream.StatusError
+	if errors.As(err, &se) {
+		if p.unsent {
+			return se.StatusCode == fasthttp.StatusServiceUnavailable
+		}
+
+		return se.StatusCode >= fasthttp.StatusInternalServerError
+	}
+
+	if p.unsent {
+		return notSent(err)
+	}
+
+	var ne net.Error
+
+	return errors.As(err, &ne) ||
+		notSent(err) ||
+		errors.Is(err, fasthttp.ErrTimeout) ||
+		errors.Is(err, fasthttp.ErrConnectionClosed) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// notSent reports if request failed before it was sent to CSDD.
+func notSent(err error) bool {
+	var oe *net.OpError
+	if errors.As(err, &oe) && oe.Op == "dial" {
+		return true
+	}
+
+	var de *net.DNSError
+
+	return errors.As(err, &de) ||
+		errors.Is(err, fasthttp.ErrDialTimeout) ||
+		errors.Is(err, fasthttp.ErrTLSHandshakeTimeout) ||
+		errors.Is(err, fasthttp.ErrNoFreeConns)
+}
+
+// post calls CSDD service retrying failed requests according to the service retry policy.
+//
+// Returns ErrCircuitOpen without calling CSDD while circuit breaker is open.
+func (s *csddService) post(ctx context.Context, serviceName string, body any, response any) error {
+	policy := s.retryPolicy(serviceName)
+
+	for retry := 0; ; retry++ {
+		_, err := s.breaker.Execute(func() (struct{}, error) {
+			return struct{}{}, s.http.PostJSON(ctx, s.config.CSDDUrl, body, response)
+		})
+		if err == nil {
+			return nil
+		}
+
+		if errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests) {
+			return ErrCircuitOpen
+		}
+
+		if retry+1 >= policy.attempts || ctx.Err() != nil || !policy.retryable(err) {
+			return err
+		}
+
+		delay := policy.backoff(retry)
+
+		s.log(ctx).Warn("CSDD call failed, retrying",
+			zap.String("service", serviceName),
+			zap.Int("retry", retry+1),
+			zap.Duration("delay", delay),
+			zap.Error(err))
+
+		trace.SpanFromContext(ctx).AddEvent("retry", trace.WithAttributes(
+			attribute.Int("retry", retry+1),
+			attribute.String("error", err.Error())))
+
+		metrics.ObserveCSDDRetry(serviceName)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
@@ -0,0 +1,134 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package csdd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"git.zzdats.lv/edim/api-mdl/upstream"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestRetryPolicyRetryable(t *testing.T) {
+	dialErr := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	readErr := &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}
+
+	tests := []struct {
+		name string
+		err  error
+		// retryable is the expected result for idempotent services.
+		retryable bool
+		// unsent is the expected result for services that can be retried only if request has not been sent.
+		unsent bool
+	}{
+		{
+			name:      "service unavailable",
+			err:       &upstream.StatusError{StatusCode: fasthttp.StatusServiceUnavailable},
+			retryable: true,
+			unsent:    true,
+		},
+		{
+			name:      "internal server error",
+			err:       &upstream.StatusError{StatusCode: fasthttp.StatusInternalServerError},
+			retryable: true,
+		},
+		{
+			name: "not found",
+			err:  &upstream.StatusError{StatusCode: fasthttp.StatusNotFound},
+		},
+		{
+			name:      "dial error",
+			err:       dialErr,
+			retryable: true,
+			unsent:    true,
+		},
+		{
+			name:      "wrapped dial error",
+			err:       fmt.Errorf("request failed: %w", dialErr),
+			retryable: true,
+			unsent:    true,
+		},
+		{
+			name:      "DNS error",
+			err:       &net.DNSError{Err: "no such host", Name: "csdd.example.lv"},
+			retryable: true,
+			unsent:    true,
+		},
+		{
+			name:      "no free connections",
+			err:       fasthttp.ErrNoFreeConns,
+			retryable: true,
+			unsent:    true,
+		},
+		{
+			name:      "connection reset",
+			err:       readErr,
+			retryable: true,
+		},
+		{
+			name:      "timeout",
+			err:       fasthttp.ErrTimeout,
+			retryable: true,
+		},
+		{
+			name:      "connection closed",
+			err:       fasthttp.ErrConnectionClosed,
+			retryable: true,
+		},
+		{
+			name:      "unexpected EOF",
+			err:       io.ErrUnexpectedEOF,
+			retryable: true,
+		},
+		{
+			name: "invalid response",
+			err:  errors.New("invalid character '<' looking for beginning of value"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if retryable := (retryPolicy{}).retryable(tt.err); retryable != tt.retryable {
+				t.Errorf("expected retryable %t, got %t", tt.retryable, retryable)
+			}
+
+			if retryable := (retryPolicy{unsent: true}).retryable(tt.err); retryable != tt.unsent {
+				t.Errorf("expected retryable if not sent %t, got %t", tt.unsent, retryable)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := retryPolicy{
+		initial: 10 * time.Millisecond,
+		max:     50 * time.Millisecond,
+	}
+
+	tests := []struct {
+		retry int
+		max   time.Duration
+	}{
+		{retry: 0, max: 10 * time.Millisecond},
+		{retry: 1, max: 20 * time.Millisecond},
+		{retry: 2, max: 40 * time.Millisecond},
+		{retry: 3, max: 50 * time.Millisecond},
+		{retry: 64, max: 50 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("retry %d", tt.retry), func(t *testing.T) {
+			for range 100 {
+				if d := p.backoff(tt.retry); d <= 0 || d > tt.max {
+					t.Fatalf("expected backoff in (0, %s], got %s", tt.max, d)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package csdd
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"git.zzdats.lv/edim/api-mdl/routes/responses"
+	"git.zzdats.lv/edim/api-mdl/tracing"
+	"git.zzdats.lv/edim/api-mdl/vault"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// maxRotationAttempts is the number of times rotation is retried if Vault secret is changed concurrently.
+const maxRotationAttempts = 3
+
+// RequestRotation schedules password rotation check to be run in background as soon as possible.
+func (s *csddService) RequestRotation() {
+	select {
+	case s.rotate <- struct{}{}:
+	default:
+	}
+}
+
+func (s *csddService) runRotation(ctx context.Context) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.config.RotationInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.checkRotation(ctx); err != nil && ctx.Err() == nil {
+			s.app.Log().Error("CSDD password rotation check failed", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.rotate:
+		}
+	}
+}
+
+// checkRotation changes password if CSDD requests it or password in Vault is older than configured number of days.
+func (s *csddService) checkRotation(ctx context.Context) error {
+	_, err := s.rotatePassword(ctx, false)
+
+	return err
+}
+
+// RotatePassword changes CSDD password regardless of its age and returns the Vault secret version of the new password.
+func (s *csddService) RotatePassword(ctx context.Context) (int, error) {
+	return s.rotatePassword(ctx, true)
+}
+
+// rotatePassword logs in to CSDD with a dedicated session and changes password if needed or forced.
+//
+// Returns the Vault secret version of the current password.
+func (s *csddService) rotatePassword(ctx context.Context, force bool) (_ int, err error) {
+	ctx, span := tracing.Start(ctx, "csdd.rotatePassword", trace.WithAttributes(attribute.Bool("csdd.rotation.forced", force)))
+	defer tracing.End(span, &err)
+
+	// periodic check must not block logins from requests unless there is something to change
+	if !force {
+		version, needed, err := s.rotationNeeded(ctx)
+		if err != nil || !needed {
+			return version, err
+		}
+	}
+
+	// logins from requests must wait while password in Vault and CSDD can differ.
+	// Lock order is the same as in login recovery.
+	s.tokenMu.Lock()
+	defer s.tokenMu.Unlock()
+
+	// only one instance can rotate password at a time, others will
+	// read already changed password from Vault after lock is released
+	lctx, unlock, err := s.lock.Lock(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	ctx = lctx
+	defer func() { err = lockError(ctx, err) }()
+
+	if err := s.saveRecovery(ctx); err != nil {
+		return 0, err
+	}
+
+	// finish rotation that could have been interrupted
+	if err := s.reconcileRotation(ctx); err != nil {
+		return 0, err
+	}
+
+	vaultdata, err := s.vault.GetCSDDAuthData(ctx, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := s.recoveryBlocked(vaultdata); err != nil {
+		return 0, err
+	}
+
+	response, err := s.CallLogin(ctx, vaultdata)
+	if err != nil {
+		return 0, err
+	}
+
+	// wrong password is recovered on next login from request
+	if len(response.Errors) > 0 {
+		return 0, s.newError("Chk_web_Gliet", response.Errors[0].ClientMessageCode, response.Errors[0].ClientMessage)
+	}
+
+	sessionID := response.Rowset[0].SessionID
+	defer s.Logout(ctx, sessionID)
+
+	pm := response.Rowset[0].PM
+	s.lastPM.Store(int32(pm))
+	s.saveLastPM(ctx, pm)
+
+	for range maxRotationAttempts {
+		if !force && !s.rotationDue(vaultdata, pm) {
+			return vaultdata.Data.Metadata.Version, nil
+		}
+
+		s.app.Log().Info("Rotating CSDD password",
+			zap.Bool("forced", force),
+			zap.Int("pm", pm),
+			zap.Int("vault_version", vaultdata.Data.Metadata.Version))
+
+		version, err := s.ChangePassword(ctx, vaultdata, sessionID)
+		if err == nil {
+			return version, nil
+		}
+
+		var conflict *vault.ConflictError
+		if !errors.As(err, &conflict) {
+			return 0, err
+		}
+
+		// password has been changed by someone else, so re-read it
+		// and check if rotation is still needed
+		s.app.Log().Warn("CSDD password in Vault has been changed during rotation", zap.Error(err))
+
+		vaultdata, err = s.vault.GetCSDDAuthData(ctx, 0)
+		if err != nil {
+			return 0, err
+		}
+
+		// password expiry flag is valid only for the password session was logged in with
+		// and forced rotation is satisfied by password changed by someone else
+		pm = 0
+		force = false
+	}
+
+	return 0, errors.New("CSDD password rotation failed: Vault secret keeps changing")
+}
+
+// rotationNeeded checks without holding any locks if password rotation is due, interrupted rotation must be reconciled
+// or login recovery must be saved.
+//
+// Returns the Vault secret version of the current password.
+func (s *csddService) rotationNeeded(ctx context.Context) (int, bool, error) {
+	meta, err := s.vault.GetCSDDMetadata(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+
+	if r := parseRotation(meta.Data.CustomMetadata); r != nil && r.State != rotationCommitted {
+		return 0, true, nil
+	}
+
+	if s.recoveryPending() {
+		return 0, true, nil
+	}
+
+	vaultdata, err := s.vault.GetCSDDAuthData(ctx, 0)
+	if err != nil {
+		return 0, false, err
+	}
+
+	if err := s.recoveryBlocked(vaultdata); err != nil {
+		return 0, false, err
+	}
+
+	response, err := s.CallLogin(ctx, vaultdata)
+	if err != nil {
+		return 0, false, err
+	}
+
+	if len(response.Errors) > 0 {
+		// wrong password is recovered on next login from request
+		return 0, false, s.newError("Chk_web_Gliet", response.Errors[0].ClientMessageCode, response.Errors[0].ClientMessage)
+	}
+
+	s.Logout(ctx, response.Rowset[0].SessionID)
+
+	pm := response.Rowset[0].PM
+	s.lastPM.Store(int32(pm))
+
+	if meta.Data.CustomMetadata[metadataLastPM] != strconv.Itoa(pm) {
+		s.saveLastPMLocked(ctx, pm)
+	}
+
+	return vaultdata.Data.Metadata.Version, s.rotationDue(vaultdata, pm), nil
+}
+
+// rotationDue returns true if CSDD has requested password change
+// or if password is older than "s.config.ChangePasswordDays" days.
+func (s *csddService) rotationDue(vaultdata *responses.VaultGetDataResponse, pm int) bool {
+	if passwordExpired(pm) {
+		return true
+	}
+
+	due, ok := s.rotationDueTime(vaultdata.Data.Metadata.CreatedTime)
+
+	return ok && due.Before(time.Now())
+}
+
+// rotationDueTime returns time when password created at the given time must be rotated because of its age.
+//
+// Returns false if rotation by age is disabled.
+func (s *csddService) rotationDueTime(created time.Time) (time.Time, bool) {
+	days, _ := strconv.Atoi(s.config.CSDDChangePasswordDays)
+	if days <= 0 {
+		return time.Time{}, false
+	}
+
+	return created.Add(time.Duration(24*days) * time.Hour), true
+}
+
+// passwordExpired returns true if CSDD password management flag requires new password (PM == 1 vai PM == 2).
+func passwordExpired(pm int) bool {
+	return pm == 1 || pm == 2
+}
@@ -0,0 +1,224 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package csdd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"strconv"
+	"time"
+
+	"git.zzdats.lv/edim/api-mdl/metrics"
+	"git.zzdats.lv/edim/api-mdl/routes/responses"
+
+	"go.uber.org/zap"
+)
+
+// rotationState is the state of password rotation persisted in Vault secret custom metadata.
+type rotationState string
+
+const (
+	// rotationPending means that new password may have been saved to Vault but is not yet accepted by CSDD.
+	rotationPending rotationState = "pending"
+	// rotationConfirmed means that CSDD has accepted the new password.
+	rotationConfirmed rotationState = "confirmed"
+	// rotationCommitted means that the latest password in Vault is the one accepted by CSDD.
+	rotationCommitted rotationState = "committed"
+)
+
+// Custom metadata keys.
+const (
+	metadataRotationState   = "rotation_state"
+	metadataRotationFrom    = "rotation_from_version"
+	metadataRotationTo      = "rotation_to_version"
+	metadataRotationUpdated = "rotation_updated"
+	metadataRotationResult  = "rotation_result"
+	metadataRotationError   = "rotation_error"
+
+	metadataLastPM           = "last_pm"
+	metadataSuspendedVersion = "login_suspended_version"
+	metadataSuspendedUntil   = "login_suspended_until"
+)
+
+// Rotation results.
+const (
+	rotationResultRotated    = "rotated"
+	rotationResultRolledBack = "rolled_back"
+	rotationResultFailed     = "failed"
+	rotationResultReconciled = "reconciled"
+)
+
+// rotation is the persisted state of password rotation.
+type rotation struct {
+	State rotationState
+	// From is the Vault secret version with the password before rotation.
+	From int
+	// To is the Vault secret version with the new password.
+	To      int
+	Updated time.Time
+	// Result is the outcome of the rotation once it is committed.
+	Result string
+	// Error is the error message of the failed rotation.
+	Error string
+}
+
+func parseRotation(metadata map[string]string) *rotation {
+	state, ok := metadata[metadataRotationState]
+	if !ok {
+		return nil
+	}
+
+	r := &rotation{
+		State: rotationState(state),
+	}
+
+	r.From, _ = strconv.Atoi(metadata[metadataRotationFrom])
+	r.To, _ = strconv.Atoi(metadata[metadataRotationTo])
+	r.Updated, _ = time.Parse(time.RFC3339, metadata[metadataRotationUpdated])
+	r.Result = metadata[metadataRotationResult]
+	r.Error = metadata[metadataRotationError]
+
+	return r
+}
+
+func (r *rotation) metadata() map[string]string {
+	return map[string]string{
+		metadataRotationState:   string(r.State),
+		metadataRotationFrom:    strconv.Itoa(r.From),
+		metadataRotationTo:      strconv.Itoa(r.To),
+		metadataRotationUpdated: r.Updated.UTC().Format(time.RFC3339),
+		metadataRotationResult:  r.Result,
+		metadataRotationError:   r.Error,
+	}
+}
+
+// saveRotation persists rotation state to Vault.
+func (s *csddService) saveRotation(ctx context.Context, r *rotation, state rotationState) error {
+	r.State = state
+	r.Updated = time.Now()
+
+	if state == rotationCommitted {
+		metrics.ObservePasswordRotation(r.Result)
+	}
+
+	if err := s.updateMetadata(ctx, r.metadata()); err != nil {
+		s.log(ctx).Error("Failed to save CSDD password rotation state",
+			zap.String("state", string(state)), zap.Error(err))
+
+		return err
+	}
+
+	return nil
+}
+
+// updateMetadata merges values into custom metadata of the CSDD password secret
+// as Vault replaces all custom metadata on every write.
+//
+// Must be called holding the rotation lock as Vault does not support check-and-set for metadata.
+func (s *csddService) updateMetadata(ctx context.Context, values map[string]string) error {
+	s.metadataMu.Lock()
+	defer s.metadataMu.Unlock()
+
+	meta, err := s.vault.GetCSDDMetadata(ctx)
+	if err != nil {
+		return err
+	}
+
+	metadata := make(map[string]string, len(meta.Data.CustomMetadata)+len(values))
+	maps.Copy(metadata, meta.Data.CustomMetadata)
+	maps.Copy(metadata, values)
+
+	return s.vault.SetCSDDCustomMetadata(ctx, metadata)
+}
+
+// reconcileRotation finishes rotation that has been interrupted (e.g. by process crash)
+// by probing both candidate passwords and updating Vault to the one CSDD accepts.
+func (s *csddService) reconcileRotation(ctx context.Context) error {
+	meta, err := s.vault.GetCSDDMetadata(ctx)
+	if err != nil {
+		return err
+	}
+
+	r := parseRotation(meta.Data.CustomMetadata)
+	if r == nil || r.State == rotationCommitted {
+		return nil
+	}
+
+	s.app.Log().Warn("Reconciling interrupted CSDD password rotation",
+		zap.String("state", string(r.State)),
+		zap.Int("from_version", r.From),
+		zap.Int("to_version", r.To))
+
+	latest, err := s.vault.GetCSDDAuthData(ctx, 0)
+	if err != nil {
+		return err
+	}
+
+	// CSDD has accepted the new password and it is still the latest one in Vault
+	r.Result = rotationResultReconciled
+
+	if r.State == rotationConfirmed && latest.Data.Metadata.Version == r.To {
+		return s.saveRotation(ctx, r, rotationCommitted)
+	}
+
+	ok, err := s.probeLogin(ctx, latest)
+	if err != nil {
+		return err
+	}
+
+	if ok {
+		r.To = latest.Data.Metadata.Version
+
+		return s.saveRotation(ctx, r, rotationCommitted)
+	}
+
+	if r.From == 0 || r.From == latest.Data.Metadata.Version {
+		return errors.New("CSDD does not accept the latest password from Vault")
+	}
+
+	previous, err := s.vault.GetCSDDAuthData(ctx, r.From)
+	if err != nil {
+		return err
+	}
+
+	ok, err = s.probeLogin(ctx, previous)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return fmt.Errorf("CSDD accepts neither password from Vault version %d nor %d", latest.Data.Metadata.Version, r.From)
+	}
+
+	res, err := s.vault.ChangeVaultData(ctx, previous.Data.Data.Password, latest.Data.Metadata.Version)
+	if err != nil {
+		return err
+	}
+
+	r.To = res.Data.Version
+
+	return s.saveRotation(ctx, r, rotationCommitted)
+}
+
+// probeLogin checks if CSDD accepts the password by logging in and out.
+func (s *csddService) probeLogin(ctx context.Context, vaultdata *responses.VaultGetDataResponse) (bool, error) {
+	response, err := s.CallLogin(ctx, vaultdata)
+	if err != nil {
+		return false, err
+	}
+
+	if len(response.Errors) > 0 {
+		err := s.newError("Chk_web_Gliet", response.Errors[0].ClientMessageCode, response.Errors[0].ClientMessage)
+		if errors.Is(err, ErrInvalidPassword) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	s.Logout(ctx, response.Rowset[0].SessionID)
+
+	return true, nil
+}
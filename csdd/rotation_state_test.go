@@ -0,0 +1,281 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package csdd
+
+import (
+	"context"
+	"maps"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"git.zzdats.lv/edim/api-mdl/csdd/csddmock"
+	"git.zzdats.lv/edim/api-mdl/routes/responses"
+	"git.zzdats.lv/edim/api-mdl/upstream"
+	"git.zzdats.lv/edim/api-mdl/vault"
+
+	"azugo.io/core"
+)
+
+const testUser = "test-user"
+
+// testVault is in-memory CSDD password secret with versions and custom metadata.
+type testVault struct {
+	mu       sync.Mutex
+	versions []string
+	metadata map[string]string
+}
+
+func newTestVault(passwords ...string) *testVault {
+	return &testVault{
+		versions: passwords,
+		metadata: make(map[string]string),
+	}
+}
+
+func (v *testVault) GetToken(_ context.Context) (string, error) {
+	return "token", nil
+}
+
+func (v *testVault) GetCSDDAuthData(_ context.Context, version int) (*responses.VaultGetDataResponse, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if version == 0 {
+		version = len(v.versions)
+	}
+
+	data := &responses.VaultGetDataResponse{}
+	data.Data.Data.Password = v.versions[version-1]
+	data.Data.Metadata.Version = version
+	data.Data.Metadata.CreatedTime = time.Now()
+	data.Data.Metadata.CustomMetadata = maps.Clone(v.metadata)
+
+	return data, nil
+}
+
+func (v *testVault) ChangeVaultData(_ context.Context, newpsw string, version int) (*responses.VaultSaveDataPostResponse, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if version != len(v.versions) {
+		return nil, &vault.ConflictError{Version: version}
+	}
+
+	v.versions = append(v.versions, newpsw)
+
+	res := &responses.VaultSaveDataPostResponse{}
+	res.Data.Version = len(v.versions)
+
+	return res, nil
+}
+
+func (v *testVault) GetCSDDMetadata(_ context.Context) (*responses.VaultMetadataResponse, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	meta := &responses.VaultMetadataResponse{}
+	meta.Data.CurrentVersion = len(v.versions)
+	meta.Data.CustomMetadata = maps.Clone(v.metadata)
+
+	return meta, nil
+}
+
+func (v *testVault) GetCSDDVersions(_ context.Context) ([]int, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	versions := make([]int, 0, len(v.versions))
+	for i := len(v.versions); i > 0; i-- {
+		versions = append(versions, i)
+	}
+
+	return versions, nil
+}
+
+func (v *testVault) SetCSDDCustomMetadata(_ context.Context, metadata map[string]string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.metadata = maps.Clone(metadata)
+
+	return nil
+}
+
+// newTestService returns CSDD service that uses CSDD mock and the given Vault without background rotation.
+func newTestService(t *testing.T, v vault.Service, mock *csddmock.Server) *csddService {
+	t.Helper()
+
+	server := httptest.NewServer(mock)
+	t.Cleanup(server.Close)
+
+	config := &Configuration{
+		CSDDUrl:             server.URL,
+		CSDDUserName:        testUser,
+		Client:              &upstream.Configuration{MinTLSVersion: "1.2"},
+		LoginRetryAttempts:  1,
+		QueryRetryAttempts:  1,
+		RetryInitialBackoff: time.Millisecond,
+		RetryMaxBackoff:     time.Millisecond,
+		BreakerFailures:     3,
+		BreakerOpenTimeout:  time.Minute,
+	}
+
+	client, err := upstream.New("test", config.Client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &csddService{
+		app:    core.New(),
+		config: config,
+		vault:  v,
+		lock:   noopLocker{},
+		http:   client,
+		codes:  codes(config),
+		rotate: make(chan struct{}, 1),
+	}
+
+	s.lastPM.Store(-1)
+	s.breaker = s.newBreaker()
+
+	return s
+}
+
+func TestReconcileRotation(t *testing.T) {
+	tests := []struct {
+		name string
+		// rotation is the persisted rotation state, nil if there has been no rotation.
+		rotation *rotation
+		// password is the password accepted by CSDD.
+		password string
+
+		err      bool
+		logins   int
+		state    rotationState
+		result   string
+		to       int
+		versions []string
+	}{
+		{
+			name:     "no rotation",
+			password: "Parole-2",
+			versions: []string{"Parole-1", "Parole-2"},
+		},
+		{
+			name:     "committed",
+			rotation: &rotation{State: rotationCommitted, From: 1, To: 2, Result: rotationResultRotated},
+			password: "Parole-2",
+			state:    rotationCommitted,
+			result:   rotationResultRotated,
+			to:       2,
+			versions: []string{"Parole-1", "Parole-2"},
+		},
+		{
+			name:     "confirmed with the latest version",
+			rotation: &rotation{State: rotationConfirmed, From: 1, To: 2},
+			password: "Parole-2",
+			state:    rotationCommitted,
+			result:   rotationResultReconciled,
+			to:       2,
+			versions: []string{"Parole-1", "Parole-2"},
+		},
+		{
+			name:     "confirmed with changed latest version",
+			rotation: &rotation{State: rotationConfirmed, From: 1, To: 1},
+			password: "Parole-2",
+			logins:   1,
+			state:    rotationCommitted,
+			result:   rotationResultReconciled,
+			to:       2,
+			versions: []string{"Parole-1", "Parole-2"},
+		},
+		{
+			name:     "pending and CSDD accepts the latest password",
+			rotation: &rotation{State: rotationPending, From: 1, To: 2},
+			password: "Parole-2",
+			logins:   1,
+			state:    rotationCommitted,
+			result:   rotationResultReconciled,
+			to:       2,
+			versions: []string{"Parole-1", "Parole-2"},
+		},
+		{
+			name:     "pending and CSDD accepts the previous password",
+			rotation: &rotation{State: rotationPending, From: 1, To: 2},
+			password: "Parole-1",
+			logins:   2,
+			state:    rotationCommitted,
+			result:   rotationResultReconciled,
+			to:       3,
+			versions: []string{"Parole-1", "Parole-2", "Parole-1"},
+		},
+		{
+			name:     "pending and CSDD accepts neither password",
+			rotation: &rotation{State: rotationPending, From: 1, To: 2},
+			password: "Parole-3",
+			err:      true,
+			logins:   2,
+			state:    rotationPending,
+			to:       2,
+			versions: []string{"Parole-1", "Parole-2"},
+		},
+		{
+			name:     "pending without previous version",
+			rotation: &rotation{State: rotationPending},
+			password: "Parole-3",
+			err:      true,
+			logins:   1,
+			state:    rotationPending,
+			versions: []string{"Parole-1", "Parole-2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			v := newTestVault("Parole-1", "Parole-2")
+			if tt.rotation != nil {
+				v.metadata = tt.rotation.metadata()
+			}
+
+			mock := csddmock.New(csddmock.DefaultFixtures())
+			mock.SetPassword(testUser, tt.password)
+
+			s := newTestService(t, v, mock)
+
+			err := s.reconcileRotation(ctx)
+			if tt.err && err == nil {
+				t.Fatal("expected reconciliation to fail")
+			}
+
+			if !tt.err && err != nil {
+				t.Fatalf("expected reconciliation to succeed, got %v", err)
+			}
+
+			if logins := mock.Calls("Chk_web_Gliet"); logins != tt.logins {
+				t.Errorf("expected %d CSDD logins, got %d", tt.logins, logins)
+			}
+
+			if state := rotationState(v.metadata[metadataRotationState]); state != tt.state {
+				t.Errorf("expected rotation state %q, got %q", tt.state, state)
+			}
+
+			if result := v.metadata[metadataRotationResult]; tt.rotation != nil && result != tt.result {
+				t.Errorf("expected rotation result %q, got %q", tt.result, result)
+			}
+
+			if to := v.metadata[metadataRotationTo]; tt.rotation != nil && to != strconv.Itoa(tt.to) {
+				t.Errorf("expected rotation to version %d, got %s", tt.to, to)
+			}
+
+			if !slices.Equal(v.versions, tt.versions) {
+				t.Errorf("expected Vault versions %v, got %v", tt.versions, v.versions)
+			}
+		})
+	}
+}
@@ -3,15 +3,23 @@
 package csdd
 
 import (
+	"context"
+
 	"git.zzdats.lv/edim/api-mdl/routes/responses"
 	"git.zzdats.lv/edim/api-mdl/vault"
 
-	"azugo.io/azugo"
 	"azugo.io/core"
 )
 
 type Service interface {
-	GetCSDDData(ctx *azugo.Context, code string) (*responses.GetDataResponse, error)
+	GetCSDDData(ctx context.Context, code string) (*responses.GetDataResponse, error)
+	RotatePassword(ctx context.Context) (int, error)
+	CredentialStatus(ctx context.Context) (*responses.CredentialsResponse, error)
+	Ping(ctx context.Context) error
+	IdleSessions() int
+	CredentialsError(ctx context.Context) error
+	RotationError(ctx context.Context) error
+	CircuitState() string
 }
 
 func New(app *core.App, config *Configuration, vault vault.Service) (Service, error) {
@@ -0,0 +1,174 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package csdd
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// session is a logged in CSDD session.
+type session struct {
+	id       string
+	created  time.Time
+	lastUsed time.Time
+	// reused is true if session has already been used for a previous request.
+	reused bool
+}
+
+// sessionPool keeps a bounded number of live CSDD sessions that are reused across requests.
+type sessionPool struct {
+	login  func(ctx context.Context) (string, error)
+	logout func(ctx context.Context, sessionID string)
+
+	idleTimeout time.Duration
+	maxAge      time.Duration
+
+	// slots limits the number of sessions that can be live at the same time.
+	slots chan struct{}
+
+	mu     sync.Mutex
+	idle   []*session
+	closed bool
+}
+
+func newSessionPool(
+	size int,
+	idleTimeout time.Duration,
+	maxAge time.Duration,
+	login func(ctx context.Context) (string, error),
+	logout func(ctx context.Context, sessionID string),
+) *sessionPool {
+	if size < 1 {
+		size = 1
+	}
+
+	return &sessionPool{
+		login:       login,
+		logout:      logout,
+		idleTimeout: idleTimeout,
+		maxAge:      maxAge,
+		slots:       make(chan struct{}, size),
+	}
+}
+
+// Acquire returns idle session or logs in to CSDD if there are no idle sessions available.
+//
+// Session must be returned to the pool with Release or Discard.
+func (p *sessionPool) Acquire(ctx context.Context) (*session, error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	for {
+		sess := p.popIdle()
+		if sess == nil {
+			break
+		}
+
+		// CSDD has probably already expired this session, so do not risk using it
+		if p.expired(sess) {
+			p.logout(ctx, sess.id)
+
+			continue
+		}
+
+		sess.reused = true
+
+		return sess, nil
+	}
+
+	id, err := p.login(ctx)
+	if err != nil {
+		<-p.slots
+
+		return nil, err
+	}
+
+	now := time.Now()
+
+	return &session{
+		id:       id,
+		created:  now,
+		lastUsed: now,
+	}, nil
+}
+
+// expired returns true if session has been idle or logged in for too long to be reused.
+func (p *sessionPool) expired(sess *session) bool {
+	if p.idleTimeout > 0 && time.Since(sess.lastUsed) > p.idleTimeout {
+		return true
+	}
+
+	return p.maxAge > 0 && time.Since(sess.created) > p.maxAge
+}
+
+// Release returns healthy session back to the pool.
+func (p *sessionPool) Release(ctx context.Context, sess *session) {
+	defer func() { <-p.slots }()
+
+	sess.lastUsed = time.Now()
+
+	p.mu.Lock()
+
+	if !p.closed && (p.maxAge <= 0 || time.Since(sess.created) <= p.maxAge) {
+		p.idle = append(p.idle, sess)
+		p.mu.Unlock()
+
+		return
+	}
+
+	p.mu.Unlock()
+
+	p.logout(ctx, sess.id)
+}
+
+// Discard logs out from the session and frees its slot in the pool.
+func (p *sessionPool) Discard(ctx context.Context, sess *session) {
+	defer func() { <-p.slots }()
+
+	p.logout(ctx, sess.id)
+}
+
+// Close logs out from all idle sessions. Sessions in use are logged out when released.
+func (p *sessionPool) Close(ctx context.Context, log *zap.Logger) {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.closed = true
+	p.mu.Unlock()
+
+	log.Debug("Closing CSDD sessions", zap.Int("count", len(idle)))
+
+	for _, sess := range idle {
+		p.logout(ctx, sess.id)
+	}
+}
+
+// IdleCount returns the number of idle sessions in the pool.
+func (p *sessionPool) IdleCount() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.idle)
+}
+
+func (p *sessionPool) popIdle() *session {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.idle) == 0 {
+		return nil
+	}
+
+	// Use most recently used session as it is the least likely to be expired
+	sess := p.idle[len(p.idle)-1]
+	p.idle = p.idle[:len(p.idle)-1]
+
+	return sess
+}
@@ -0,0 +1,211 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package csdd
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testSessions counts CSDD logins and logouts of the session pool.
+type testSessions struct {
+	mu      sync.Mutex
+	logins  int
+	logouts []string
+}
+
+func (s *testSessions) login(_ context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.logins++
+
+	return strconv.Itoa(s.logins), nil
+}
+
+func (s *testSessions) logout(_ context.Context, sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.logouts = append(s.logouts, sessionID)
+}
+
+func (s *testSessions) pool(size int, idleTimeout, maxAge time.Duration) *sessionPool {
+	return newSessionPool(size, idleTimeout, maxAge, s.login, s.logout)
+}
+
+func TestSessionPoolReuse(t *testing.T) {
+	tests := []struct {
+		name        string
+		idleTimeout time.Duration
+		maxAge      time.Duration
+		// age is the time session is in use before it is released.
+		age time.Duration
+		// idle is the time session is idle before it is acquired again.
+		idle time.Duration
+
+		reused  bool
+		logouts int
+	}{
+		{
+			name:        "reused while idle timeout is not reached",
+			idleTimeout: time.Minute,
+			maxAge:      time.Hour,
+			reused:      true,
+		},
+		{
+			name:        "evicted after idle timeout",
+			idleTimeout: 20 * time.Millisecond,
+			maxAge:      time.Hour,
+			idle:        50 * time.Millisecond,
+			logouts:     1,
+		},
+		{
+			name:        "evicted after max age while idle",
+			idleTimeout: time.Minute,
+			maxAge:      40 * time.Millisecond,
+			age:         20 * time.Millisecond,
+			idle:        30 * time.Millisecond,
+			logouts:     1,
+		},
+		{
+			name:        "logged out on release after max age",
+			idleTimeout: time.Minute,
+			maxAge:      20 * time.Millisecond,
+			age:         50 * time.Millisecond,
+			logouts:     1,
+		},
+		{
+			name:   "reused without limits",
+			idle:   20 * time.Millisecond,
+			reused: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			sessions := &testSessions{}
+			p := sessions.pool(1, tt.idleTimeout, tt.maxAge)
+
+			first, err := p.Acquire(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			time.Sleep(tt.age)
+			p.Release(ctx, first)
+			time.Sleep(tt.idle)
+
+			second, err := p.Acquire(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if reused := second.id == first.id; reused != tt.reused {
+				t.Errorf("expected session reused %t, got %t", tt.reused, reused)
+			}
+
+			if second.reused != tt.reused {
+				t.Errorf("expected session reused flag %t, got %t", tt.reused, second.reused)
+			}
+
+			if len(sessions.logouts) != tt.logouts {
+				t.Errorf("expected %d logouts, got %d", tt.logouts, len(sessions.logouts))
+			}
+		})
+	}
+}
+
+func TestSessionPoolLIFO(t *testing.T) {
+	ctx := context.Background()
+	sessions := &testSessions{}
+	p := sessions.pool(2, time.Minute, time.Hour)
+
+	first, err := p.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := p.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p.Release(ctx, first)
+	p.Release(ctx, second)
+
+	sess, err := p.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sess.id != second.id {
+		t.Errorf("expected most recently used session %q, got %q", second.id, sess.id)
+	}
+
+	if sessions.logins != 2 {
+		t.Errorf("expected 2 logins, got %d", sessions.logins)
+	}
+}
+
+func TestSessionPoolDiscard(t *testing.T) {
+	ctx := context.Background()
+	sessions := &testSessions{}
+	p := sessions.pool(1, time.Minute, time.Hour)
+
+	sess, err := p.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p.Discard(ctx, sess)
+
+	if len(sessions.logouts) != 1 || sessions.logouts[0] != sess.id {
+		t.Errorf("expected session %q to be logged out, got %v", sess.id, sessions.logouts)
+	}
+
+	if n := p.IdleCount(); n != 0 {
+		t.Errorf("expected no idle sessions, got %d", n)
+	}
+
+	// slot of discarded session must be freed
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+
+	next, err := p.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("expected session after discard, got %v", err)
+	}
+
+	if next.id == sess.id || next.reused {
+		t.Errorf("expected new session, got %q", next.id)
+	}
+}
+
+func TestSessionPoolSize(t *testing.T) {
+	ctx := context.Background()
+	sessions := &testSessions{}
+	p := sessions.pool(1, time.Minute, time.Hour)
+
+	sess, err := p.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+
+	if _, err := p.Acquire(wctx); err == nil {
+		t.Fatal("expected acquire to wait for free slot")
+	}
+
+	p.Release(ctx, sess)
+
+	if _, err := p.Acquire(ctx); err != nil {
+		t.Errorf("expected session after release, got %v", err)
+	}
+}
@@ -0,0 +1,185 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package csdd
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"git.zzdats.lv/edim/api-mdl/routes/responses"
+
+	"go.uber.org/zap"
+)
+
+// CredentialStatus returns the state of CSDD credentials and password rotation.
+func (s *csddService) CredentialStatus(ctx context.Context) (*responses.CredentialsResponse, error) {
+	vaultdata, err := s.vault.GetCSDDAuthData(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	meta, err := s.vault.GetCSDDMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &responses.CredentialsResponse{
+		VaultVersion: vaultdata.Data.Metadata.Version,
+		CreatedTime:  vaultdata.Data.Metadata.CreatedTime,
+	}
+
+	if due, ok := s.rotationDueTime(vaultdata.Data.Metadata.CreatedTime); ok {
+		days := int(math.Floor(time.Until(due).Hours() / 24))
+		status.RotationDueTime = &due
+		status.DaysUntilRotation = &days
+	}
+
+	if pm := s.knownPM(meta.Data.CustomMetadata); pm >= 0 {
+		status.LastPM = &pm
+	}
+
+	if r := parseRotation(meta.Data.CustomMetadata); r != nil {
+		status.LastRotation = &responses.RotationStatus{
+			State:       string(r.State),
+			Result:      r.Result,
+			Error:       r.Error,
+			FromVersion: r.From,
+			ToVersion:   r.To,
+			UpdatedTime: r.Updated,
+		}
+		status.PendingReconciliation = r.State != rotationCommitted
+	}
+
+	s.statusMu.Lock()
+	f := s.recoveryFailed
+	s.statusMu.Unlock()
+
+	if f == nil {
+		f = parseRecoveryFailure(meta.Data.CustomMetadata)
+	}
+
+	if f != nil && time.Now().Before(f.until) {
+		status.LoginSuspendedUntil = &f.until
+	}
+
+	return status, nil
+}
+
+// saveLastPM persists password management flag returned by CSDD on login in Vault secret custom metadata.
+//
+// Must be called holding the rotation lock.
+func (s *csddService) saveLastPM(ctx context.Context, pm int) {
+	if err := s.updateMetadata(ctx, map[string]string{metadataLastPM: strconv.Itoa(pm)}); err != nil {
+		s.log(ctx).Warn("Failed to save CSDD password management flag", zap.Error(err))
+	}
+}
+
+// saveLastPMLocked acquires the rotation lock and persists password management flag.
+func (s *csddService) saveLastPMLocked(ctx context.Context, pm int) {
+	lctx, unlock, err := s.lock.Lock(ctx)
+	if err != nil {
+		s.log(ctx).Warn("Failed to save CSDD password management flag", zap.Error(err))
+
+		return
+	}
+	defer unlock()
+
+	s.saveLastPM(lctx, pm)
+}
+
+// Ping checks if CSDD service is reachable by opening TCP connection to it without logging in.
+func (s *csddService) Ping(ctx context.Context) error {
+	return s.http.Ping(ctx, s.config.CSDDUrl)
+}
+
+// IdleSessions returns the number of logged in CSDD sessions ready to be used.
+func (s *csddService) IdleSessions() int {
+	return s.sessions.IdleCount()
+}
+
+// CredentialsError returns error if CSDD has not accepted any known password and login is suspended
+// by this or any other instance and password in Vault has not been changed since.
+func (s *csddService) CredentialsError(ctx context.Context) error {
+	meta, err := s.vault.GetCSDDMetadata(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.statusMu.Lock()
+	f := s.recoveryFailed
+	s.statusMu.Unlock()
+
+	for _, f := range []*recoveryFailure{f, parseRecoveryFailure(meta.Data.CustomMetadata)} {
+		if f == nil || f.version != meta.Data.CurrentVersion {
+			continue
+		}
+
+		if err := f.err(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RotationError returns error if password rotation has been interrupted and is not reconciled within rotation interval
+// or if the last rotation has failed and password must already be changed.
+//
+// Reconciliation of interrupted rotation is requested as soon as the rotation lock could have expired.
+func (s *csddService) RotationError(ctx context.Context) error {
+	meta, err := s.vault.GetCSDDMetadata(ctx)
+	if err != nil {
+		return err
+	}
+
+	r := parseRotation(meta.Data.CustomMetadata)
+	if r == nil {
+		return nil
+	}
+
+	if r.State != rotationCommitted {
+		if time.Since(r.Updated) < s.config.RotationLockTTL {
+			return nil
+		}
+
+		// rotation has been interrupted, so reconcile it without waiting for the next check
+		s.RequestRotation()
+
+		if time.Since(r.Updated) < s.config.RotationInterval {
+			return nil
+		}
+
+		return fmt.Errorf("CSDD password rotation is %s since %s", r.State, r.Updated.Format(time.RFC3339))
+	}
+
+	if r.Result != rotationResultFailed && r.Result != rotationResultRolledBack {
+		return nil
+	}
+
+	created := meta.Data.Versions[strconv.Itoa(meta.Data.CurrentVersion)].CreatedTime
+	due, ok := s.rotationDueTime(created)
+
+	if passwordExpired(s.knownPM(meta.Data.CustomMetadata)) || (ok && due.Before(time.Now())) {
+		return fmt.Errorf("CSDD password rotation has failed at %s and password is due to be changed: %s",
+			r.Updated.Format(time.RFC3339), r.Error)
+	}
+
+	return nil
+}
+
+// knownPM returns password management flag returned by CSDD on the last login of this instance
+// or the one persisted by any instance, -1 if unknown.
+func (s *csddService) knownPM(metadata map[string]string) int {
+	if pm := int(s.lastPM.Load()); pm >= 0 {
+		return pm
+	}
+
+	if pm, err := strconv.Atoi(metadata[metadataLastPM]); err == nil {
+		return pm
+	}
+
+	return -1
+}
@@ -0,0 +1,32 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package encryption
+
+import (
+	"azugo.io/core/validation"
+	"github.com/spf13/viper"
+)
+
+// Configuration represents the configuration for the response encryption.
+type Configuration struct {
+	// ClientKeysFile is the JWKS file with registered encryption keys of clients, kid of each key is the client ID.
+	ClientKeysFile string `mapstructure:"client_keys_file" validate:"omitempty,file"`
+	// RequiredClients are the client IDs that always receive encrypted responses.
+	RequiredClients []string `mapstructure:"required_clients"`
+}
+
+func (c *Configuration) Bind(prefix string, v *viper.Viper) {
+	_ = v.BindEnv(prefix+".client_keys_file", "RESPONSE_ENCRYPTION_CLIENT_KEYS_FILE")
+	_ = v.BindEnv(prefix+".required_clients", "RESPONSE_ENCRYPTION_REQUIRED_CLIENTS")
+}
+
+// Validate response encryption configuration section.
+func (c *Configuration) Validate(valid *validation.Validate) error {
+	if err := valid.Struct(c); err != nil {
+		return err
+	}
+
+	_, err := New(c)
+
+	return err
+}
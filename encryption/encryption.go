@@ -0,0 +1,153 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+// Package encryption encrypts API responses as JWE to the client encryption key.
+package encryption
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+const (
+	// ContentType is the content type of JWE in compact serialization.
+	ContentType = "application/jose"
+
+	// HeaderKey is the request header with base64url encoded encryption JWK supplied by the caller.
+	HeaderKey = "X-Encryption-Key"
+)
+
+// ErrInvalidKey is returned when encryption key is not a valid EC public key.
+var ErrInvalidKey = errors.New("invalid encryption key")
+
+// Encrypter encrypts responses with ECDH-ES key agreement and A256GCM content encryption.
+type Encrypter struct {
+	keys     map[string]*jose.JSONWebKey
+	required []string
+}
+
+// New returns response encrypter with registered client keys.
+func New(config *Configuration) (*Encrypter, error) {
+	e := &Encrypter{
+		keys:     make(map[string]*jose.JSONWebKey),
+		required: config.RequiredClients,
+	}
+
+	if config.ClientKeysFile == "" {
+		return e, nil
+	}
+
+	data, err := os.ReadFile(config.ClientKeysFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client encryption keys file: %w", err)
+	}
+
+	jwks := &jose.JSONWebKeySet{}
+	if err := json.Unmarshal(data, jwks); err != nil {
+		return nil, fmt.Errorf("invalid client encryption keys JWKS: %w", err)
+	}
+
+	for i := range jwks.Keys {
+		key := &jwks.Keys[i]
+
+		if key.KeyID == "" {
+			return nil, errors.New("client encryption key must have client ID as kid")
+		}
+
+		if err := validateKey(key); err != nil {
+			return nil, fmt.Errorf("client %s encryption key: %w", key.KeyID, err)
+		}
+
+		if _, ok := e.keys[key.KeyID]; ok {
+			return nil, fmt.Errorf("duplicate encryption key of client %s", key.KeyID)
+		}
+
+		e.keys[key.KeyID] = key
+	}
+
+	return e, nil
+}
+
+// Required returns true if responses to the client must be encrypted.
+func (e *Encrypter) Required(clientID string) bool {
+	return clientID != "" && slices.Contains(e.required, clientID)
+}
+
+// ClientKey returns registered encryption key of the client or nil if client has not registered a key.
+func (e *Encrypter) ClientKey(clientID string) *jose.JSONWebKey {
+	return e.keys[clientID]
+}
+
+// Key returns encryption key from base64url encoded JWK.
+func Key(value string) (*jose.JSONWebKey, error) {
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidKey, err)
+	}
+
+	key := &jose.JSONWebKey{}
+	if err := key.UnmarshalJSON(b); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidKey, err)
+	}
+
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// validateKey checks that key is EC public key on a curve supported by ECDH-ES.
+func validateKey(key *jose.JSONWebKey) error {
+	pub, ok := key.Key.(*ecdsa.PublicKey)
+	if !ok || !key.Valid() {
+		return ErrInvalidKey
+	}
+
+	switch pub.Curve {
+	case elliptic.P256(), elliptic.P384(), elliptic.P521():
+	default:
+		return ErrInvalidKey
+	}
+
+	if key.Use != "" && key.Use != "enc" {
+		return fmt.Errorf("%w: key use must be enc", ErrInvalidKey)
+	}
+
+	return nil
+}
+
+// Encrypt returns JWE in compact serialization of the payload with the content type set in cty header.
+//
+// Content type is set without parameters and application/ prefix, signed JWT payload has cty JWT as nested JWT.
+func (e *Encrypter) Encrypt(payload []byte, contentType string, key *jose.JSONWebKey) (string, error) {
+	cty, _, _ := strings.Cut(contentType, ";")
+	cty = strings.TrimPrefix(strings.TrimSpace(cty), "application/")
+	if strings.EqualFold(cty, "jwt") {
+		cty = "JWT"
+	}
+
+	enc, err := jose.NewEncrypter(
+		jose.A256GCM,
+		jose.Recipient{Algorithm: jose.ECDH_ES, Key: key.Key, KeyID: key.KeyID},
+		(&jose.EncrypterOptions{}).WithContentType(jose.ContentType(cty)),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	jwe, err := enc.Encrypt(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt response: %w", err)
+	}
+
+	return jwe.CompactSerialize()
+}
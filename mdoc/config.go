@@ -0,0 +1,48 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package mdoc
+
+import (
+	"time"
+
+	"azugo.io/core/validation"
+	"github.com/spf13/viper"
+)
+
+// Configuration represents the configuration for the mdoc issuance.
+type Configuration struct {
+	// IssuerKeyFile is the PEM file with document signer EC private key. Issuance is disabled if not set.
+	IssuerKeyFile string `mapstructure:"issuer_key_file" validate:"required_with=IssuerCertFile,omitempty,file"`
+	// IssuerCertFile is the PEM file with document signer certificate followed by intermediate certificates.
+	IssuerCertFile string `mapstructure:"issuer_cert_file" validate:"required_with=IssuerKeyFile,omitempty,file"`
+	// Validity is the maximum validity period of issued mdoc. Mdoc is never valid after driving licence expiry date.
+	Validity time.Duration `mapstructure:"validity" validate:"gt=0"`
+}
+
+func (c *Configuration) Bind(prefix string, v *viper.Viper) {
+	v.SetDefault(prefix+".validity", 30*24*time.Hour)
+
+	_ = v.BindEnv(prefix+".issuer_key_file", "MDOC_ISSUER_KEY_FILE")
+	_ = v.BindEnv(prefix+".issuer_cert_file", "MDOC_ISSUER_CERT_FILE")
+	_ = v.BindEnv(prefix+".validity", "MDOC_VALIDITY")
+}
+
+// Enabled returns true if mdoc issuer key is configured.
+func (c *Configuration) Enabled() bool {
+	return c.IssuerKeyFile != ""
+}
+
+// Validate mdoc configuration section.
+func (c *Configuration) Validate(valid *validation.Validate) error {
+	if err := valid.Struct(c); err != nil {
+		return err
+	}
+
+	if !c.Enabled() {
+		return nil
+	}
+
+	_, err := loadSigner(c.IssuerKeyFile, c.IssuerCertFile)
+
+	return err
+}
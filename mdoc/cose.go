@@ -0,0 +1,136 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package mdoc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"git.zzdats.lv/edim/api-mdl/utils"
+)
+
+// COSE header labels and algorithms (RFC 9052, RFC 9053, RFC 9360).
+const (
+	headerAlgorithm = 1
+	headerX5Chain   = 33
+
+	algES256 = -7
+	algES384 = -35
+	algES512 = -36
+)
+
+// coseSign1 is the COSE_Sign1 structure.
+type coseSign1 struct {
+	_           struct{} `cbor:",toarray"`
+	Protected   []byte
+	Unprotected map[int]any
+	Payload     []byte
+	Signature   []byte
+}
+
+// signer signs COSE_Sign1 structures with the document signer key.
+type signer struct {
+	key   *ecdsa.PrivateKey
+	chain [][]byte
+	alg   int
+	hash  crypto.Hash
+}
+
+// loadSigner loads document signer EC private key and certificate chain from PEM files.
+func loadSigner(keyFile, certFile string) (*signer, error) {
+	key, err := utils.LoadECKey(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("issuer key: %w", err)
+	}
+
+	data, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read issuer certificate file: %w", err)
+	}
+
+	var chain [][]byte
+
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type == "CERTIFICATE" {
+			chain = append(chain, block.Bytes)
+		}
+	}
+
+	if len(chain) == 0 {
+		return nil, errors.New("issuer certificate file does not contain any PEM certificates")
+	}
+
+	cert, err := x509.ParseCertificate(chain[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse issuer certificate: %w", err)
+	}
+
+	if !key.PublicKey.Equal(cert.PublicKey) {
+		return nil, errors.New("issuer certificate does not match issuer key")
+	}
+
+	s := &signer{
+		key:   key,
+		chain: chain,
+	}
+
+	switch key.Curve {
+	case elliptic.P256():
+		s.alg, s.hash = algES256, crypto.SHA256
+	case elliptic.P384():
+		s.alg, s.hash = algES384, crypto.SHA384
+	case elliptic.P521():
+		s.alg, s.hash = algES512, crypto.SHA512
+	default:
+		return nil, fmt.Errorf("unsupported issuer key curve %s", key.Curve.Params().Name)
+	}
+
+	return s, nil
+}
+
+// sign returns COSE_Sign1 of the payload with algorithm in protected header and certificate chain
+// in unprotected header.
+func (s *signer) sign(payload []byte) (*coseSign1, error) {
+	protected, err := encMode.Marshal(map[int]any{headerAlgorithm: s.alg})
+	if err != nil {
+		return nil, err
+	}
+
+	toBeSigned, err := encMode.Marshal([]any{"Signature1", protected, []byte{}, payload})
+	if err != nil {
+		return nil, err
+	}
+
+	h := s.hash.New()
+	h.Write(toBeSigned)
+
+	r, ss, err := ecdsa.Sign(rand.Reader, s.key, h.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+
+	// COSE signature is r and s as fixed length big-endian integers
+	size := (s.key.Curve.Params().BitSize + 7) / 8
+	signature := make([]byte, 2*size)
+	r.FillBytes(signature[:size])
+	ss.FillBytes(signature[size:])
+
+	var x5chain any = s.chain
+	if len(s.chain) == 1 {
+		x5chain = s.chain[0]
+	}
+
+	return &coseSign1{
+		Protected:   protected,
+		Unprotected: map[int]any{headerX5Chain: x5chain},
+		Payload:     payload,
+		Signature:   signature,
+	}, nil
+}
@@ -0,0 +1,365 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+// Package mdoc issues driving licence as ISO/IEC 18013-5 mdoc.
+package mdoc
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	mrand "math/rand/v2"
+	"time"
+
+	"git.zzdats.lv/edim/api-mdl/routes/requests"
+	"git.zzdats.lv/edim/api-mdl/routes/responses"
+	"git.zzdats.lv/edim/api-mdl/utils"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+const (
+	// DocType is the mDL document type.
+	DocType = "org.iso.18013.5.1.mDL"
+	// Namespace is the mDL data element namespace.
+	Namespace = "org.iso.18013.5.1"
+	// ContentType is the content type of the issued mdoc.
+	ContentType = "application/cbor"
+
+	// saltSize is the size of random salt of the data element, at least 16 bytes are required.
+	saltSize = 32
+
+	tagEncodedCBOR = 24
+	tagFullDate    = 1004
+)
+
+var (
+	// ErrInvalidDeviceKey is returned when holder device key is missing or is not a valid EC public key.
+	ErrInvalidDeviceKey = errors.New("invalid device key")
+	// ErrIncompleteData is returned when driving licence data lacks mandatory mDL data element.
+	ErrIncompleteData = errors.New("driving licence data is incomplete")
+	// ErrExpired is returned when driving licence has expired, so mdoc would never be valid.
+	ErrExpired = errors.New("driving licence has expired")
+)
+
+// encMode encodes dates as tdate (tag 0) with no fractions of seconds.
+var encMode = func() cbor.EncMode {
+	em, err := cbor.EncOptions{
+		Sort:    cbor.SortCoreDeterministic,
+		Time:    cbor.TimeRFC3339,
+		TimeTag: cbor.EncTagRequired,
+	}.EncMode()
+	if err != nil {
+		panic(err)
+	}
+
+	return em
+}()
+
+// issuerSigned is the issuer signed part of the mdoc.
+type issuerSigned struct {
+	NameSpaces map[string][]cbor.Tag `cbor:"nameSpaces"`
+	IssuerAuth *coseSign1            `cbor:"issuerAuth"`
+}
+
+type issuerSignedItem struct {
+	DigestID          uint64 `cbor:"digestID"`
+	Random            []byte `cbor:"random"`
+	ElementIdentifier string `cbor:"elementIdentifier"`
+	ElementValue      any    `cbor:"elementValue"`
+}
+
+type mobileSecurityObject struct {
+	Version         string                       `cbor:"version"`
+	DigestAlgorithm string                       `cbor:"digestAlgorithm"`
+	ValueDigests    map[string]map[uint64][]byte `cbor:"valueDigests"`
+	DeviceKeyInfo   deviceKeyInfo                `cbor:"deviceKeyInfo"`
+	DocType         string                       `cbor:"docType"`
+	ValidityInfo    validityInfo                 `cbor:"validityInfo"`
+}
+
+type deviceKeyInfo struct {
+	DeviceKey *coseKey `cbor:"deviceKey"`
+}
+
+// coseKey is the EC2 COSE_Key (RFC 9053).
+type coseKey struct {
+	Kty int    `cbor:"1,keyasint"`
+	Crv int    `cbor:"-1,keyasint"`
+	X   []byte `cbor:"-2,keyasint"`
+	Y   []byte `cbor:"-3,keyasint"`
+}
+
+type validityInfo struct {
+	Signed     time.Time `cbor:"signed"`
+	ValidFrom  time.Time `cbor:"validFrom"`
+	ValidUntil time.Time `cbor:"validUntil"`
+}
+
+type drivingPrivilege struct {
+	VehicleCategoryCode string          `cbor:"vehicle_category_code"`
+	IssueDate           any             `cbor:"issue_date,omitempty"`
+	ExpiryDate          any             `cbor:"expiry_date,omitempty"`
+	Codes               []privilegeCode `cbor:"codes,omitempty"`
+}
+
+type privilegeCode struct {
+	Code string `cbor:"code"`
+	Sign string `cbor:"sign,omitempty"`
+}
+
+// Issuer issues mdoc signed with the document signer key.
+type Issuer struct {
+	config *Configuration
+	signer *signer
+}
+
+// New returns mdoc issuer with the configured document signer key.
+func New(config *Configuration) (*Issuer, error) {
+	s, err := loadSigner(config.IssuerKeyFile, config.IssuerCertFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Issuer{
+		config: config,
+		signer: s,
+	}, nil
+}
+
+// DeviceKey returns holder device public key from JWK.
+func DeviceKey(jwk *requests.JWK) (*ecdh.PublicKey, error) {
+	if jwk == nil || jwk.Kty != "EC" {
+		return nil, ErrInvalidDeviceKey
+	}
+
+	var curve ecdh.Curve
+
+	switch jwk.Crv {
+	case "P-256":
+		curve = ecdh.P256()
+	case "P-384":
+		curve = ecdh.P384()
+	case "P-521":
+		curve = ecdh.P521()
+	default:
+		return nil, fmt.Errorf("%w: unsupported curve %q", ErrInvalidDeviceKey, jwk.Crv)
+	}
+
+	x, errX := base64.RawURLEncoding.DecodeString(jwk.X)
+	y, errY := base64.RawURLEncoding.DecodeString(jwk.Y)
+
+	if errX != nil || errY != nil || len(x) != len(y) {
+		return nil, fmt.Errorf("%w: invalid coordinates", ErrInvalidDeviceKey)
+	}
+
+	key, err := curve.NewPublicKey(append(append([]byte{4}, x...), y...))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidDeviceKey, err)
+	}
+
+	return key, nil
+}
+
+// Issue returns CBOR encoded IssuerSigned structure of the driving licence bound to the holder device key.
+func (i *Issuer) Issue(data *responses.MDLResponse, deviceKey *ecdh.PublicKey) ([]byte, error) {
+	elements, err := dataElements(data)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+
+	validUntil := i.validUntil(now, data.ExpiryDate)
+	if !validUntil.After(now) {
+		return nil, fmt.Errorf("%w on %s", ErrExpired, time.Time(data.ExpiryDate).Format(time.DateOnly))
+	}
+
+	// digest IDs are shuffled to not reveal the order or number of undisclosed data elements
+	ids := mrand.Perm(len(elements))
+	items := make([]cbor.Tag, 0, len(elements))
+	digests := make(map[uint64][]byte, len(elements))
+
+	for n, e := range elements {
+		salt := make([]byte, saltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+
+		item, err := encMode.Marshal(&issuerSignedItem{
+			DigestID:          uint64(ids[n]),
+			Random:            salt,
+			ElementIdentifier: e.id,
+			ElementValue:      e.value,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", e.id, err)
+		}
+
+		tagged := cbor.Tag{Number: tagEncodedCBOR, Content: item}
+
+		b, err := encMode.Marshal(tagged)
+		if err != nil {
+			return nil, err
+		}
+
+		digest := sha256.Sum256(b)
+		digests[uint64(ids[n])] = digest[:]
+		items = append(items, tagged)
+	}
+
+	key, err := coseKeyOf(deviceKey)
+	if err != nil {
+		return nil, err
+	}
+
+	mso, err := encMode.Marshal(&mobileSecurityObject{
+		Version:         "1.0",
+		DigestAlgorithm: "SHA-256",
+		ValueDigests:    map[string]map[uint64][]byte{Namespace: digests},
+		DeviceKeyInfo:   deviceKeyInfo{DeviceKey: key},
+		DocType:         DocType,
+		ValidityInfo: validityInfo{
+			Signed:     now,
+			ValidFrom:  now,
+			ValidUntil: validUntil,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := encMode.Marshal(cbor.Tag{Number: tagEncodedCBOR, Content: mso})
+	if err != nil {
+		return nil, err
+	}
+
+	auth, err := i.signer.sign(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign mobile security object: %w", err)
+	}
+
+	return encMode.Marshal(&issuerSigned{
+		NameSpaces: map[string][]cbor.Tag{Namespace: items},
+		IssuerAuth: auth,
+	})
+}
+
+// validUntil returns the end of mdoc validity that is not later than the end of driving licence expiry date.
+func (i *Issuer) validUntil(now time.Time, expiry utils.Date) time.Time {
+	until := now.Add(i.config.Validity)
+
+	y, m, d := time.Time(expiry).Date()
+	if end := time.Date(y, m, d, 23, 59, 59, 0, time.UTC); end.Before(until) {
+		return end
+	}
+
+	return until
+}
+
+// coseKeyOf returns COSE_Key of the EC public key.
+func coseKeyOf(key *ecdh.PublicKey) (*coseKey, error) {
+	var crv int
+
+	switch key.Curve() {
+	case ecdh.P256():
+		crv = 1
+	case ecdh.P384():
+		crv = 2
+	case ecdh.P521():
+		crv = 3
+	default:
+		return nil, ErrInvalidDeviceKey
+	}
+
+	// uncompressed point 0x04 || x || y
+	b := key.Bytes()[1:]
+
+	return &coseKey{
+		Kty: 2,
+		Crv: crv,
+		X:   b[:len(b)/2],
+		Y:   b[len(b)/2:],
+	}, nil
+}
+
+type element struct {
+	id    string
+	value any
+}
+
+// dataElements returns mDL data elements of the driving licence. All elements are mandatory.
+func dataElements(data *responses.MDLResponse) ([]element, error) {
+	portrait, err := utils.DecodeBase64(data.Portrait)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid portrait: %w", ErrIncompleteData, err)
+	}
+
+	elements := []element{
+		{"family_name", data.FamilyName},
+		{"given_name", data.GivenName},
+		{"birth_date", fullDate(data.BirthDate)},
+		{"issue_date", fullDate(data.IssueDate)},
+		{"expiry_date", fullDate(data.ExpiryDate)},
+		{"issuing_country", data.IssuingCountry},
+		{"issuing_authority", data.IssuingAuthority},
+		{"document_number", data.DocumentNumber},
+		{"portrait", portrait},
+		{"driving_privileges", drivingPrivileges(data.DrivingPrivileges)},
+		{"un_distinguishing_sign", data.UnDistinguishingSign},
+	}
+
+	for _, e := range elements {
+		switch v := e.value.(type) {
+		case nil:
+			return nil, fmt.Errorf("%w: %s is missing", ErrIncompleteData, e.id)
+		case string:
+			if v == "" {
+				return nil, fmt.Errorf("%w: %s is missing", ErrIncompleteData, e.id)
+			}
+		case []byte:
+			if len(v) == 0 {
+				return nil, fmt.Errorf("%w: %s is missing", ErrIncompleteData, e.id)
+			}
+		}
+	}
+
+	return elements, nil
+}
+
+// drivingPrivileges returns driving privileges as defined in ISO/IEC 18013-5 7.2.4.
+//
+// CSDD returns restriction code in the value (e.g. 01.06), so it is used as the code.
+func drivingPrivileges(privileges []responses.DrivingPrivilege) []drivingPrivilege {
+	result := make([]drivingPrivilege, 0, len(privileges))
+
+	for _, p := range privileges {
+		dp := drivingPrivilege{
+			VehicleCategoryCode: p.VehicleCategoryCode,
+			IssueDate:           fullDate(p.IssueDate),
+			ExpiryDate:          fullDate(p.ExpiryDate),
+		}
+
+		for _, c := range p.Code {
+			dp.Codes = append(dp.Codes, privilegeCode{
+				Code: c.Value,
+				Sign: c.Sign,
+			})
+		}
+
+		result = append(result, dp)
+	}
+
+	return result
+}
+
+// fullDate returns date as full-date (tag 1004) or nil if date is not set.
+func fullDate(d utils.Date) any {
+	if time.Time(d).IsZero() {
+		return nil
+	}
+
+	return cbor.Tag{Number: tagFullDate, Content: d.String()}
+}
@@ -0,0 +1,177 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+// Package metrics contains Prometheus metrics of CSDD, Vault and MDL requests.
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "api_mdl"
+
+// Outcome label values.
+const (
+	// OutcomeSuccess is used when upstream has successfully processed the request.
+	OutcomeSuccess = "success"
+	// OutcomeError is used when upstream has returned error in the response.
+	OutcomeError = "error"
+	// OutcomeNetworkError is used when request has failed before response was received.
+	OutcomeNetworkError = "network_error"
+)
+
+// Registry contains all service metrics.
+var Registry = prometheus.NewRegistry()
+
+var factory = promauto.With(Registry)
+
+var (
+	csddRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "csdd",
+		Name:      "requests_total",
+		Help:      "Number of CSDD service calls by service name, outcome and CSDD client message code.",
+	}, []string{"service", "outcome", "code"})
+
+	csddRequestDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "csdd",
+		Name:      "request_duration_seconds",
+		Help:      "Duration of CSDD service calls by service name and outcome.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"service", "outcome"})
+
+	csddRetries = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "csdd",
+		Name:      "retries_total",
+		Help:      "Number of retried CSDD service calls by service name.",
+	}, []string{"service"})
+
+	csddCircuitState = factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "csdd",
+		Name:      "circuit_state",
+		Help:      "State of CSDD circuit breaker: 0 - closed, 1 - half-open, 2 - open.",
+	})
+
+	vaultTokenRefreshes = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "vault",
+		Name:      "token_refreshes_total",
+		Help:      "Number of Vault AppRole logins by outcome.",
+	}, []string{"outcome"})
+
+	vaultRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "vault",
+		Name:      "requests_total",
+		Help:      "Number of Vault CSDD secret requests by operation and outcome.",
+	}, []string{"operation", "outcome"})
+
+	vaultRequestDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "vault",
+		Name:      "request_duration_seconds",
+		Help:      "Duration of Vault CSDD secret requests by operation.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	passwordRotations = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "csdd",
+		Name:      "password_rotations_total",
+		Help:      "Number of CSDD password rotations by result.",
+	}, []string{"result"})
+
+	passwordRecoveries = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "csdd",
+		Name:      "password_recoveries_total",
+		Help:      "Number of CSDD password recoveries after F-00011 error by result.",
+	}, []string{"result"})
+
+	mdlRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "mdl",
+		Name:      "requests_total",
+		Help:      "Number of MDL API requests by route and response status code.",
+	}, []string{"route", "status"})
+
+	mdlRequestDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "mdl",
+		Name:      "request_duration_seconds",
+		Help:      "Duration of MDL API requests by route and response status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "status"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// ObserveCSDD records CSDD service call.
+//
+// Code is the CSDD client message code of the first error in the response, if any.
+func ObserveCSDD(service string, start time.Time, err error, code string) {
+	outcome := OutcomeSuccess
+
+	switch {
+	case err != nil:
+		outcome = OutcomeNetworkError
+	case code != "":
+		outcome = OutcomeError
+	}
+
+	csddRequests.WithLabelValues(service, outcome, code).Inc()
+	csddRequestDuration.WithLabelValues(service, outcome).Observe(time.Since(start).Seconds())
+}
+
+// ObserveCSDDRetry records retry of failed CSDD service call.
+func ObserveCSDDRetry(service string) {
+	csddRetries.WithLabelValues(service).Inc()
+}
+
+// SetCSDDCircuitState records state of CSDD circuit breaker.
+func SetCSDDCircuitState(state int) {
+	csddCircuitState.Set(float64(state))
+}
+
+// ObserveVaultTokenRefresh records Vault AppRole login.
+func ObserveVaultTokenRefresh(outcome string) {
+	vaultTokenRefreshes.WithLabelValues(outcome).Inc()
+}
+
+// ObserveVault records Vault CSDD secret request.
+//
+// Operation is one of read, write, metadata_read or metadata_write.
+func ObserveVault(operation string, start time.Time, outcome string) {
+	vaultRequests.WithLabelValues(operation, outcome).Inc()
+	vaultRequestDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+// ObservePasswordRotation records finished CSDD password rotation.
+func ObservePasswordRotation(result string) {
+	passwordRotations.WithLabelValues(result).Inc()
+}
+
+// ObservePasswordRecovery records finished CSDD password recovery.
+func ObservePasswordRecovery(result string) {
+	passwordRecoveries.WithLabelValues(result).Inc()
+}
+
+// ObserveMDL records MDL API request of the route.
+func ObserveMDL(route string, status int, start time.Time) {
+	s := strconv.Itoa(status)
+
+	mdlRequests.WithLabelValues(route, s).Inc()
+	mdlRequestDuration.WithLabelValues(route, s).Observe(time.Since(start).Seconds())
+}
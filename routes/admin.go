@@ -0,0 +1,73 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package routes
+
+import (
+	"git.zzdats.lv/edim/api-mdl/routes/responses"
+	"git.zzdats.lv/edim/api-mdl/tracing"
+
+	"azugo.io/azugo"
+	"go.uber.org/zap"
+)
+
+// @title Rotate CSDD password
+// @description Method changes CSDD technical user password and saves it to Vault
+// @success 200 RotatePasswordResponse responses.RotatePasswordResponse "CSDD password rotated"
+// @failure 401 {empty} "Unauthorized"
+// @failure 403 {empty} "Forbidden"
+// @failure 502 Problem responses.Problem "urn:problem-type:api-mdl:register-error - CSDD returned an error"
+// @failure 503 Problem responses.Problem "urn:problem-type:api-mdl:register-unavailable - CSDD or Vault is unavailable or password could not be rotated"
+// @route /admin/csdd/rotate-password [post].
+func (r *router) rotatePassword(ctx *azugo.Context) {
+	var err error
+
+	tctx, span := tracing.Start(ctx, "router.rotatePassword")
+	defer tracing.End(span, &err)
+
+	id := correlationID(ctx, span.SpanContext())
+	log := ctx.Log().With(zap.String("correlation_id", id), zap.String("user", userClaim(ctx, "sub")))
+
+	version, err := r.CsddService().RotatePassword(tctx)
+	if err != nil {
+		p := csddProblem(err)
+
+		log.Error("Failed to rotate CSDD password", zap.Error(err), zap.String("problem_type", p.URI))
+		problem(ctx, id, p, "")
+
+		return
+	}
+
+	log.Info("CSDD password rotated by administrator", zap.Int("vault_version", version))
+
+	ctx.JSON(&responses.RotatePasswordResponse{
+		VaultVersion: version,
+	})
+}
+
+// @title CSDD credential status
+// @description Method returns the state of CSDD technical user password and its rotation
+// @success 200 CredentialsResponse responses.CredentialsResponse "CSDD credential status"
+// @failure 401 {empty} "Unauthorized"
+// @failure 403 {empty} "Forbidden"
+// @failure 503 Problem responses.Problem "urn:problem-type:api-mdl:register-unavailable - Vault is unavailable"
+// @route /admin/csdd/credentials [get].
+func (r *router) credentials(ctx *azugo.Context) {
+	var err error
+
+	tctx, span := tracing.Start(ctx, "router.credentials")
+	defer tracing.End(span, &err)
+
+	status, err := r.CsddService().CredentialStatus(tctx)
+	if err != nil {
+		id := correlationID(ctx, span.SpanContext())
+		p := csddProblem(err)
+
+		ctx.Log().Error("Failed to get CSDD credential status",
+			zap.String("correlation_id", id), zap.Error(err), zap.String("problem_type", p.URI))
+		problem(ctx, id, p, "")
+
+		return
+	}
+
+	ctx.JSON(status)
+}
@@ -0,0 +1,72 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package routes_test
+
+import (
+	"testing"
+
+	"git.zzdats.lv/edim/api-mdl/csdd/csddmock"
+	"git.zzdats.lv/edim/api-mdl/routes/responses"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestAdminRotatePassword(t *testing.T) {
+	h := newHarness(t)
+
+	resp := h.post("/admin/csdd/rotate-password", nil)
+	expectStatus(t, resp, fasthttp.StatusOK)
+
+	rotated := &responses.RotatePasswordResponse{}
+	h.decode(resp, rotated)
+
+	if psw := h.vaultPassword(rotated.VaultVersion); psw == testPassword || psw != h.csdd.Password(testUser) {
+		t.Errorf("expected new password in Vault version %d to match CSDD password", rotated.VaultVersion)
+	}
+}
+
+func TestAdminRotatePasswordCSDDError(t *testing.T) {
+	h := newHarness(t)
+
+	h.csdd.AddFault(&csddmock.Fault{
+		Service: "Upd_web_parole",
+		Times:   1,
+		Error: &responses.ErrorResponse{
+			ClientMessageCode: "F-00050",
+			ClientMessage:     "Parole neatbilst prasībām",
+		},
+	})
+
+	resp := h.post("/admin/csdd/rotate-password", nil)
+	expectStatus(t, resp, fasthttp.StatusBadGateway)
+
+	p := h.problem(resp, "urn:problem-type:api-mdl:register-error")
+	if p.Detail != "" {
+		t.Errorf("expected no internal error details, got %q", p.Detail)
+	}
+}
+
+func TestCredentialsStatePersisted(t *testing.T) {
+	h := newHarness(t)
+
+	// password management flag of the startup rotation check
+	if pm := h.vault.CustomMetadata(secretPath)["last_pm"]; pm != "0" {
+		t.Errorf("expected last_pm 0 in Vault metadata, got %q", pm)
+	}
+
+	// CSDD accepts none of the passwords in Vault
+	h.csdd.SetPassword(testUser, "Cita-Parole-2025")
+
+	resp := h.get("/1.0/mdl")
+	expectStatus(t, resp, fasthttp.StatusServiceUnavailable)
+
+	metadata := h.vault.CustomMetadata(secretPath)
+
+	if v := metadata["login_suspended_version"]; v != "1" {
+		t.Errorf("expected login suspended for Vault version 1, got %q", v)
+	}
+
+	if metadata["login_suspended_until"] == "" {
+		t.Error("expected login suspension time in Vault metadata")
+	}
+}
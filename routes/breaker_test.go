@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package routes_test
+
+import (
+	"testing"
+
+	"git.zzdats.lv/edim/api-mdl/csdd/csddmock"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestMDLRetriesCSDDFailure(t *testing.T) {
+	h := newHarness(t)
+
+	h.csdd.AddFault(&csddmock.Fault{
+		Service: "Qry_va",
+		Times:   1,
+		Status:  fasthttp.StatusBadGateway,
+	})
+
+	resp := h.get("/1.0/mdl")
+	expectStatus(t, resp, fasthttp.StatusOK)
+
+	if calls := h.csdd.Calls("Qry_va"); calls != 2 {
+		t.Errorf("expected 2 Qry_va calls, got %d", calls)
+	}
+}
+
+func TestMDLCircuitBreaker(t *testing.T) {
+	h := newHarness(t)
+
+	h.csdd.AddFault(&csddmock.Fault{
+		Status: fasthttp.StatusServiceUnavailable,
+	})
+
+	logins := h.csdd.Calls("Chk_web_Gliet")
+
+	resp := h.get("/1.0/mdl")
+	expectStatus(t, resp, fasthttp.StatusServiceUnavailable)
+	h.problem(resp, "urn:problem-type:api-mdl:register-unavailable")
+
+	if calls := h.csdd.Calls("Chk_web_Gliet") - logins; calls != 3 {
+		t.Fatalf("expected 3 login attempts, got %d", calls)
+	}
+
+	// circuit breaker is open, so CSDD is not called anymore
+	resp = h.get("/1.0/mdl")
+	expectStatus(t, resp, fasthttp.StatusServiceUnavailable)
+	h.problem(resp, "urn:problem-type:api-mdl:register-unavailable")
+
+	if ra := string(resp.Header.Peek("Retry-After")); ra != "60" {
+		t.Errorf("expected Retry-After 60, got %q", ra)
+	}
+
+	if calls := h.csdd.Calls("Chk_web_Gliet") - logins; calls != 3 {
+		t.Errorf("expected no more login attempts, got %d", calls)
+	}
+
+	resp = h.get("/healthz")
+	expectStatus(t, resp, fasthttp.StatusServiceUnavailable)
+}
+
+func TestMDLDoesNotRetryCSDDClientError(t *testing.T) {
+	h := newHarness(t)
+
+	h.csdd.AddFault(&csddmock.Fault{
+		Service: "Qry_va",
+		Times:   1,
+		Status:  fasthttp.StatusBadRequest,
+	})
+
+	resp := h.get("/1.0/mdl")
+	expectStatus(t, resp, fasthttp.StatusServiceUnavailable)
+	h.problem(resp, "urn:problem-type:api-mdl:register-unavailable")
+
+	if calls := h.csdd.Calls("Qry_va"); calls != 1 {
+		t.Errorf("expected 1 Qry_va call, got %d", calls)
+	}
+}
+
+func TestMDLDoesNotRetryProcessedLogin(t *testing.T) {
+	h := newHarness(t)
+
+	logins := h.csdd.Calls("Chk_web_Gliet")
+
+	h.csdd.AddFault(&csddmock.Fault{
+		Service: "Chk_web_Gliet",
+		Times:   1,
+		Status:  fasthttp.StatusBadGateway,
+	})
+
+	resp := h.get("/1.0/mdl")
+	expectStatus(t, resp, fasthttp.StatusServiceUnavailable)
+	h.problem(resp, "urn:problem-type:api-mdl:register-unavailable")
+
+	if calls := h.csdd.Calls("Chk_web_Gliet") - logins; calls != 1 {
+		t.Errorf("expected 1 login attempt, got %d", calls)
+	}
+}
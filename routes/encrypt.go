@@ -0,0 +1,103 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package routes
+
+import (
+	"git.zzdats.lv/edim/api-mdl/encryption"
+	"git.zzdats.lv/edim/api-mdl/signing"
+	"git.zzdats.lv/edim/api-mdl/tracing"
+
+	"azugo.io/azugo"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/valyala/fasthttp"
+	"go.uber.org/zap"
+)
+
+// clientID returns idAuth client ID of the request from client_id or azp claim.
+func clientID(ctx *azugo.Context) string {
+	if id := userClaim(ctx, "client_id"); id != "" {
+		return id
+	}
+
+	return userClaim(ctx, "azp")
+}
+
+// encrypt middleware encrypts successful responses as JWE to the encryption key supplied in X-Encryption-Key
+// header or registered for the client.
+//
+// Requests of clients that require encryption are rejected before handler is called if there is no encryption key.
+// Detached signature is not sent in clear with encrypted response, instead the signed payload is encrypted as compact JWS.
+func (r *router) encrypt(next azugo.RequestHandler) azugo.RequestHandler {
+	return func(ctx *azugo.Context) {
+		var err error
+
+		tctx, span := tracing.Start(ctx, "router.encrypt")
+		defer tracing.End(span, &err)
+
+		client := clientID(ctx)
+
+		var key *jose.JSONWebKey
+
+		if h := ctx.Header.Get(encryption.HeaderKey); h != "" {
+			if key, err = encryption.Key(h); err != nil {
+				problem(ctx, correlationID(ctx, span.SpanContext()), problemInvalidRequest,
+					encryption.HeaderKey+" must be base64url encoded EC public key in JWK format on P-256, P-384 or P-521 curve")
+
+				return
+			}
+		} else {
+			key = r.ResponseEncrypter().ClientKey(client)
+		}
+
+		if key == nil && r.ResponseEncrypter().Required(client) {
+			problem(ctx, correlationID(ctx, span.SpanContext()), problemEncryptionRequired,
+				"Responses must be encrypted, provide encryption key in "+encryption.HeaderKey+" header")
+
+			return
+		}
+
+		restore := tracing.WithParent(ctx, tctx)
+		next(ctx)
+		restore()
+
+		resp := &ctx.Context().Response
+
+		// response depends on the encryption key header
+		if vary := resp.Header.Peek(fasthttp.HeaderVary); len(vary) > 0 {
+			resp.Header.Set(fasthttp.HeaderVary, string(vary)+", "+encryption.HeaderKey)
+		} else {
+			resp.Header.Set(fasthttp.HeaderVary, encryption.HeaderKey)
+		}
+
+		if key == nil || resp.StatusCode() != fasthttp.StatusOK {
+			return
+		}
+
+		payload, contentType := resp.Body(), string(resp.Header.ContentType())
+
+		if signature := string(resp.Header.Peek(signing.HeaderSignature)); signature != "" {
+			resp.Header.Del(signing.HeaderSignature)
+
+			var jws string
+			if jws, err = signing.Attach(signature, payload); err != nil {
+				ctx.Log().Error("Failed to attach payload to response signature", zap.Error(err))
+				problem(ctx, correlationID(ctx, span.SpanContext()), problemInternalError, "")
+
+				return
+			}
+
+			payload, contentType = []byte(jws), encryption.ContentType
+		}
+
+		jwe, err := r.ResponseEncrypter().Encrypt(payload, contentType, key)
+		if err != nil {
+			ctx.Log().Error("Failed to encrypt response", zap.String("client_id", client), zap.Error(err))
+			problem(ctx, correlationID(ctx, span.SpanContext()), problemInternalError, "")
+
+			return
+		}
+
+		ctx.ContentType(encryption.ContentType)
+		ctx.Raw([]byte(jwe))
+	}
+}
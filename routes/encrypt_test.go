@@ -0,0 +1,190 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package routes_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/valyala/fasthttp"
+)
+
+// encryptionKey generates client encryption key and returns it with its public JWK.
+func encryptionKey(t *testing.T, kid string) (*ecdsa.PrivateKey, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwk, err := json.Marshal(jose.JSONWebKey{Key: &key.PublicKey, KeyID: kid, Use: "enc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key, jwk
+}
+
+// decrypt checks that response is JWE and returns decrypted payload and its content type.
+func decrypt(t *testing.T, resp *fasthttp.Response, key *ecdsa.PrivateKey) ([]byte, string) {
+	t.Helper()
+
+	if ct := string(resp.Header.ContentType()); ct != "application/jose" {
+		t.Errorf("expected JWE content type, got %q", ct)
+	}
+
+	jwe, err := jose.ParseEncrypted(string(resp.Body()), []jose.KeyAlgorithm{jose.ECDH_ES}, []jose.ContentEncryption{jose.A256GCM})
+	if err != nil {
+		t.Fatalf("failed to parse JWE: %v", err)
+	}
+
+	payload, err := jwe.Decrypt(key)
+	if err != nil {
+		t.Fatalf("failed to decrypt JWE: %v", err)
+	}
+
+	cty, _ := jwe.Header.ExtraHeaders[jose.HeaderContentType].(string)
+
+	return payload, cty
+}
+
+func TestMDLEncrypted(t *testing.T) {
+	h := newHarness(t)
+
+	key, jwk := encryptionKey(t, "")
+
+	resp := h.get("/1.0/mdl", h.test.TestClient().WithHeader("X-Encryption-Key", base64.RawURLEncoding.EncodeToString(jwk)))
+	expectStatus(t, resp, fasthttp.StatusOK)
+
+	payload, cty := decrypt(t, resp, key)
+	if cty != "json" {
+		t.Errorf("expected cty json, got %q", cty)
+	}
+
+	mdl := make(map[string]any)
+	if err := json.Unmarshal(payload, &mdl); err != nil {
+		t.Fatalf("failed to decode decrypted response: %v", err)
+	}
+
+	if mdl["personal_administrative_number"] != testPerson || mdl["portrait"] == nil {
+		t.Errorf("unexpected decrypted response %v", mdl)
+	}
+}
+
+func TestMDLEncryptedRegisteredKey(t *testing.T) {
+	key, jwk := encryptionKey(t, testClient)
+
+	keysFile := filepath.Join(t.TempDir(), "clients.jwks")
+	if err := os.WriteFile(keysFile, []byte(`{"keys":[`+string(jwk)+`]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("RESPONSE_ENCRYPTION_CLIENT_KEYS_FILE", keysFile)
+
+	h := newHarness(t)
+
+	// signed response is encrypted as nested JWT
+	resp := h.get("/1.0/mdl", h.test.TestClient().WithHeader("Accept", "application/jwt"))
+	expectStatus(t, resp, fasthttp.StatusOK)
+
+	payload, cty := decrypt(t, resp, key)
+	if cty != "JWT" {
+		t.Errorf("expected cty JWT, got %q", cty)
+	}
+
+	jws, err := jose.ParseSigned(string(payload), []jose.SignatureAlgorithm{jose.ES256})
+	if err != nil {
+		t.Fatalf("failed to parse nested JWT: %v", err)
+	}
+
+	if _, err := jws.Verify(h.issuer.PublicKey); err != nil {
+		t.Errorf("invalid response signature: %v", err)
+	}
+
+	// other clients receive plain responses
+	h.client = "other-client"
+
+	resp = h.get("/1.0/mdl")
+	expectStatus(t, resp, fasthttp.StatusOK)
+
+	mdl := make(map[string]any)
+	h.decode(resp, &mdl)
+}
+
+func TestMDLEncryptionRequired(t *testing.T) {
+	t.Setenv("RESPONSE_ENCRYPTION_REQUIRED_CLIENTS", "other-client,"+testClient)
+
+	h := newHarness(t)
+
+	resp := h.get("/1.0/mdl")
+	expectStatus(t, resp, fasthttp.StatusBadRequest)
+
+	h.problem(resp, "urn:problem-type:api-mdl:encryption-required")
+
+	if calls := h.csdd.Calls("Qry_va"); calls != 0 {
+		t.Errorf("expected CSDD not to be called, got %d calls", calls)
+	}
+
+	key, jwk := encryptionKey(t, "")
+
+	resp = h.get("/1.0/mdl", h.test.TestClient().WithHeader("X-Encryption-Key", base64.RawURLEncoding.EncodeToString(jwk)))
+	expectStatus(t, resp, fasthttp.StatusOK)
+
+	decrypt(t, resp, key)
+}
+
+func TestMDLInvalidEncryptionKey(t *testing.T) {
+	h := newHarness(t)
+
+	resp := h.get("/1.0/mdl", h.test.TestClient().WithHeader("X-Encryption-Key", "e30"))
+	expectStatus(t, resp, fasthttp.StatusBadRequest)
+
+	h.problem(resp, "urn:problem-type:api-mdl:invalid-request")
+}
+
+func TestMDLEncryptedDetachedSignature(t *testing.T) {
+	t.Setenv("RESPONSE_SIGNING_DETACHED", "true")
+
+	h := newHarness(t)
+
+	key, jwk := encryptionKey(t, "")
+
+	resp := h.get("/1.0/mdl", h.test.TestClient().WithHeader("X-Encryption-Key", base64.RawURLEncoding.EncodeToString(jwk)))
+	expectStatus(t, resp, fasthttp.StatusOK)
+
+	if signature := resp.Header.Peek("X-JWS-Signature"); len(signature) > 0 {
+		t.Errorf("expected no signature header with encrypted response, got %q", signature)
+	}
+
+	if vary := string(resp.Header.Peek("Vary")); vary != "Accept, X-Encryption-Key" {
+		t.Errorf("expected Vary: Accept, X-Encryption-Key, got %q", vary)
+	}
+
+	// signed response is encrypted as nested JWS
+	payload, cty := decrypt(t, resp, key)
+	if cty != "jose" {
+		t.Errorf("expected cty jose, got %q", cty)
+	}
+
+	jws, err := jose.ParseSigned(string(payload), []jose.SignatureAlgorithm{jose.ES256})
+	if err != nil {
+		t.Fatalf("failed to parse nested JWS: %v", err)
+	}
+
+	mdl, err := jws.Verify(h.issuer.PublicKey)
+	if err != nil {
+		t.Fatalf("invalid response signature: %v", err)
+	}
+
+	if !json.Valid(mdl) {
+		t.Errorf("expected signed JSON payload, got %q", mdl)
+	}
+}
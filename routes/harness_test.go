@@ -0,0 +1,295 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package routes_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	app "git.zzdats.lv/edim/api-mdl"
+	"git.zzdats.lv/edim/api-mdl/csdd/csddmock"
+	"git.zzdats.lv/edim/api-mdl/routes"
+	"git.zzdats.lv/edim/api-mdl/routes/responses"
+	"git.zzdats.lv/edim/api-mdl/vault/vaultmock"
+
+	"azugo.io/azugo"
+	"azugo.io/azugo/token"
+	"azugo.io/azugo/user"
+	"github.com/spf13/cobra"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	testUser     = "test-user"
+	testPassword = "Tests-Parole-2025"
+	testRoleID   = "api-mdl"
+	testSecretID = "api-mdl-secret"
+	secretPath   = "secret/csdd"
+	passwordKey  = "edim-csdd-service-password"
+	testPerson   = "32000000001"
+	testIssuer   = "https://mdl.example.lv"
+	testClient   = "test-client"
+)
+
+// harness runs application with fake idAuth, Vault and CSDD.
+type harness struct {
+	t *testing.T
+
+	app   *app.App
+	test  *azugo.TestApp
+	csdd  *csddmock.Server
+	vault *vaultmock.Server
+
+	// issuer is the certificate of mdoc document signer, SD-JWT VC issuer and response signing key.
+	issuer *x509.Certificate
+
+	// person is the personal code of the authenticated user.
+	person string
+	// client is the idAuth client ID of the request.
+	client string
+}
+
+func newHarness(t *testing.T) *harness {
+	t.Helper()
+
+	h := &harness{
+		t:      t,
+		csdd:   csddmock.New(csddmock.DefaultFixtures()),
+		person: testPerson,
+		client: testClient,
+		vault: vaultmock.New(vaultmock.Options{
+			RoleID:   testRoleID,
+			SecretID: testSecretID,
+			TokenTTL: time.Hour,
+		}),
+	}
+
+	h.vault.Put(secretPath, map[string]any{passwordKey: testPassword})
+
+	csddServer := httptest.NewServer(h.csdd)
+	t.Cleanup(csddServer.Close)
+
+	vaultServer := httptest.NewServer(h.vault)
+	t.Cleanup(vaultServer.Close)
+
+	t.Setenv("ENVIRONMENT", "development")
+	t.Setenv("IDAUTH_URL", "http://127.0.0.1:1")
+	t.Setenv("IDAUTH_CLIENT_ID", "api-mdl-data")
+	t.Setenv("IDAUTH_CLIENT_SECRET", "secret")
+	t.Setenv("VAULT_LOGIN_URL", vaultServer.URL+"/v1/auth/approle/login")
+	t.Setenv("VAULT_DATA_URL", vaultServer.URL+"/v1/"+"secret/data/csdd")
+	t.Setenv("VAULT_ROLE_ID", testRoleID)
+	t.Setenv("VAULT_SECRET_ID", testSecretID)
+	t.Setenv("CSDD_URL", csddServer.URL)
+	t.Setenv("CSDD_USERNAME", testUser)
+	t.Setenv("CSDD_CHANGE_PASSWORD_DAYS", "10")
+	t.Setenv("CSDD_SYSTEM_GUID", "AAA-BBBB-CCCCC-DDDDDDDD")
+	t.Setenv("CSDD_SYSTEM_NAME", "TEST")
+	t.Setenv("CSDD_ROTATION_INTERVAL", "1h")
+	t.Setenv("CSDD_RETRY_INITIAL_BACKOFF", "1ms")
+	t.Setenv("CSDD_RETRY_MAX_BACKOFF", "5ms")
+	t.Setenv("CSDD_BREAKER_FAILURES", "3")
+	t.Setenv("CSDD_BREAKER_OPEN_TIMEOUT", "1m")
+
+	h.issuer = writeIssuer(t)
+
+	a, err := app.New(&cobra.Command{Use: "test"}, "test", app.WithAuthentication(h.authenticate))
+	if err != nil {
+		t.Fatalf("failed to create app: %v", err)
+	}
+
+	if err := routes.Init(a); err != nil {
+		t.Fatalf("failed to init routes: %v", err)
+	}
+
+	h.app = a
+	h.test = azugo.NewTestApp(a.App)
+	h.test.Start(t)
+	t.Cleanup(h.test.Stop)
+
+	// wait for the password rotation check run on start to finish
+	h.eventually("initial password rotation check", func() bool {
+		return h.csdd.Calls("Del_Fses") > 0
+	})
+
+	return h
+}
+
+// authenticate is the fake idAuth middleware that authenticates every request as h.person of h.client.
+func (h *harness) authenticate(next azugo.RequestHandler) azugo.RequestHandler {
+	return func(ctx *azugo.Context) {
+		ctx.SetUser(user.New(map[string]token.ClaimStrings{
+			"sub":       {h.person},
+			"code":      {h.person},
+			"client_id": {h.client},
+			"scope":     {"citizen", "admin"},
+		}))
+
+		next(ctx)
+	}
+}
+
+// writeIssuer generates self-signed issuer key and certificate and configures application to use them
+// for mdoc, SD-JWT VC and response signing.
+func writeIssuer(t *testing.T) *x509.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate issuer key: %v", err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Test Document Signer", Country: []string{"LV"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create issuer certificate: %v", err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to encode issuer key: %v", err)
+	}
+
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "issuer.key")
+	certFile := filepath.Join(dir, "issuer.crt")
+
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("failed to write issuer key: %v", err)
+	}
+
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("failed to write issuer certificate: %v", err)
+	}
+
+	t.Setenv("MDOC_ISSUER_KEY_FILE", keyFile)
+	t.Setenv("MDOC_ISSUER_CERT_FILE", certFile)
+	t.Setenv("SDJWT_KEY_FILE", keyFile)
+	t.Setenv("SDJWT_ISSUER", testIssuer)
+	t.Setenv("RESPONSE_SIGNING_KEY_FILE", keyFile)
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse issuer certificate: %v", err)
+	}
+
+	return cert
+}
+
+// get sends GET request to the application.
+func (h *harness) get(path string, options ...azugo.TestClientOption) *fasthttp.Response {
+	h.t.Helper()
+
+	resp, err := h.test.TestClient().Get(path, options...)
+	if err != nil {
+		h.t.Fatalf("GET %s failed: %v", path, err)
+	}
+
+	return resp
+}
+
+// post sends POST request with JSON body to the application.
+func (h *harness) post(path string, body any) *fasthttp.Response {
+	h.t.Helper()
+
+	b, err := json.Marshal(body)
+	if err != nil {
+		h.t.Fatalf("failed to encode request body: %v", err)
+	}
+
+	client := h.test.TestClient()
+
+	resp, err := client.Post(path, b, client.WithHeader("Content-Type", "application/json"))
+	if err != nil {
+		h.t.Fatalf("POST %s failed: %v", path, err)
+	}
+
+	return resp
+}
+
+// decode unmarshals JSON response body.
+func (h *harness) decode(resp *fasthttp.Response, v any) {
+	h.t.Helper()
+
+	if err := json.Unmarshal(resp.Body(), v); err != nil {
+		h.t.Fatalf("failed to decode response %q: %v", resp.Body(), err)
+	}
+}
+
+// problem decodes problem details response and checks its type.
+func (h *harness) problem(resp *fasthttp.Response, typ string) *responses.Problem {
+	h.t.Helper()
+
+	if ct := string(resp.Header.ContentType()); ct != "application/problem+json" {
+		h.t.Errorf("expected problem details content type, got %q", ct)
+	}
+
+	p := &responses.Problem{}
+	h.decode(resp, p)
+
+	if p.Type != typ {
+		h.t.Errorf("expected problem type %q, got %q", typ, p.Type)
+	}
+
+	if p.Status != resp.StatusCode() {
+		h.t.Errorf("expected problem status %d, got %d", resp.StatusCode(), p.Status)
+	}
+
+	return p
+}
+
+// vaultPassword returns password from the given Vault secret version or the current version if version is 0.
+func (h *harness) vaultPassword(version int) string {
+	h.t.Helper()
+
+	data, ok := h.vault.Get(secretPath, version)
+	if !ok {
+		h.t.Fatalf("Vault secret version %d not found", version)
+	}
+
+	psw, _ := data[passwordKey].(string)
+
+	return psw
+}
+
+// eventually waits until condition is true.
+func (h *harness) eventually(msg string, cond func() bool) {
+	h.t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+
+	for !cond() {
+		if time.Now().After(deadline) {
+			h.t.Fatalf("timed out waiting for %s", msg)
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// expectStatus checks response status code.
+func expectStatus(t *testing.T, resp *fasthttp.Response, status int) {
+	t.Helper()
+
+	if resp.StatusCode() != status {
+		t.Fatalf("expected status %d, got %d: %s", status, resp.StatusCode(), resp.Body())
+	}
+}
@@ -3,7 +3,12 @@
 package routes
 
 import (
+	"context"
+	"sync"
+	"time"
+
 	"azugo.io/azugo"
+	"github.com/valyala/fasthttp"
 )
 
 type HealthzStatus string
@@ -28,19 +33,185 @@ const (
 	HealthzWarn HealthzStatus = "warn"
 )
 
+// healthzCacheTTL is the time health check results are reused, so that probes do not overload dependencies.
+const healthzCacheTTL = 15 * time.Second
+
+// healthzCheckTimeout is the maximum time of a single dependency check.
+const healthzCheckTimeout = 5 * time.Second
+
 // HealthzResponse is the data returned by the health endpoint, which will be marshaled to JSON format.
 type HealthzResponse struct {
 	Status      HealthzStatus `json:"status"`
 	Description string        `json:"description"` // a human-friendly description of the service
+	// Checks provides detailed health statuses of dependencies, key is "{componentName}:{measurementName}"
+	Checks map[string][]*HealthzCheck `json:"checks,omitempty"`
+}
+
+// HealthzCheck is the status of a single dependency check.
+//
+// ref https://datatracker.ietf.org/doc/html/draft-inadarei-api-health-check#section-4
+type HealthzCheck struct {
+	ComponentType string        `json:"componentType,omitempty"`
+	ObservedValue any           `json:"observedValue,omitempty"`
+	ObservedUnit  string        `json:"observedUnit,omitempty"`
+	Status        HealthzStatus `json:"status"`
+	Time          time.Time     `json:"time"`
+	Output        string        `json:"output,omitempty"`
+}
+
+// healthChecker runs dependency checks and caches their results.
+type healthChecker struct {
+	mu      sync.Mutex
+	checks  map[string][]*HealthzCheck
+	expires time.Time
+}
+
+// check runs dependency check measuring its duration.
+func check(ctx context.Context, componentType string, fn func(ctx context.Context) error) *HealthzCheck {
+	ctx, cancel := context.WithTimeout(ctx, healthzCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := fn(ctx)
+
+	c := &HealthzCheck{
+		ComponentType: componentType,
+		ObservedValue: time.Since(start).Milliseconds(),
+		ObservedUnit:  "ms",
+		Status:        HealthzPass,
+		Time:          start.UTC(),
+	}
+
+	if err != nil {
+		c.Status = HealthzFail
+		c.Output = err.Error()
+	}
+
+	return c
+}
+
+// healthChecks returns cached dependency check results or runs checks if cached results have expired.
+func (r *router) healthChecks(ctx context.Context) map[string][]*HealthzCheck {
+	r.health.mu.Lock()
+	defer r.health.mu.Unlock()
+
+	if r.health.checks != nil && time.Now().Before(r.health.expires) {
+		return r.health.checks
+	}
+
+	checks := make(map[string][]*HealthzCheck, 7)
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+
+	run := func(name, componentType string, fn func(ctx context.Context) error) {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			c := check(ctx, componentType, fn)
+
+			mu.Lock()
+			checks[name] = []*HealthzCheck{c}
+			mu.Unlock()
+		}()
+	}
+
+	run("vault:token", "component", func(ctx context.Context) error {
+		_, err := r.VaultService().GetToken(ctx)
+
+		return err
+	})
+	run("vault:secret", "datastore", func(ctx context.Context) error {
+		_, err := r.VaultService().GetCSDDAuthData(ctx, 0)
+
+		return err
+	})
+	run("csdd:reachability", "component", r.CsddService().Ping)
+	run("csdd:rotation", "component", r.CsddService().RotationError)
+	run("csdd:credentials", "component", r.CsddService().CredentialsError)
+
+	wg.Wait()
+
+	checks["csdd:sessions"] = []*HealthzCheck{{
+		ComponentType: "component",
+		ObservedValue: r.CsddService().IdleSessions(),
+		Status:        HealthzPass,
+		Time:          time.Now().UTC(),
+	}}
+
+	checks["csdd:circuit"] = []*HealthzCheck{circuitCheck(r.CsddService().CircuitState())}
+
+	r.health.checks = checks
+	r.health.expires = time.Now().Add(healthzCacheTTL)
+
+	return checks
+}
+
+// circuitCheck returns status of CSDD circuit breaker.
+func circuitCheck(state string) *HealthzCheck {
+	c := &HealthzCheck{
+		ComponentType: "component",
+		ObservedValue: state,
+		Status:        HealthzPass,
+		Time:          time.Now().UTC(),
+	}
+
+	switch state {
+	case "open":
+		c.Status = HealthzFail
+		c.Output = "CSDD calls are failing fast after repeated failures"
+	case "half-open":
+		c.Status = HealthzWarn
+		c.Output = "CSDD calls are being probed after repeated failures"
+	}
+
+	return c
+}
+
+// healthStatus returns overall service status based on dependency checks.
+//
+// Service can not serve requests if CSDD is unreachable or circuit breaker is open.
+// Vault and CSDD credentials are needed only to log in to CSDD, so while there are logged in sessions service is degraded.
+// Interrupted or failed password rotation does not prevent serving requests yet.
+func (r *router) healthStatus(checks map[string][]*HealthzCheck) HealthzStatus {
+	if checks["csdd:reachability"][0].Status == HealthzFail || checks["csdd:circuit"][0].Status == HealthzFail {
+		return HealthzFail
+	}
+
+	if checks["vault:token"][0].Status == HealthzPass && checks["vault:secret"][0].Status == HealthzPass &&
+		checks["csdd:credentials"][0].Status == HealthzPass {
+		if checks["csdd:rotation"][0].Status == HealthzFail {
+			return HealthzWarn
+		}
+
+		return HealthzPass
+	}
+
+	if r.CsddService().IdleSessions() > 0 {
+		return HealthzWarn
+	}
+
+	return HealthzFail
 }
 
 func (r *router) healthz(ctx *azugo.Context) {
 	ctx.SkipRequestLog()
 
-	// TODO: Implement health checks
+	checks := r.healthChecks(ctx)
+	status := r.healthStatus(checks)
+
+	if status == HealthzFail {
+		ctx.StatusCode(fasthttp.StatusServiceUnavailable)
+	}
 
+	ctx.Header.Set("Cache-Control", "no-cache")
 	ctx.JSON(&HealthzResponse{
-		Status:      HealthzPass,
+		Status:      status,
 		Description: ctx.App().AppName,
+		Checks:      checks,
 	})
 }
@@ -0,0 +1,165 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package routes_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"git.zzdats.lv/edim/api-mdl/csdd/csddmock"
+	"git.zzdats.lv/edim/api-mdl/routes"
+	"git.zzdats.lv/edim/api-mdl/vault/vaultmock"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestHealthz(t *testing.T) {
+	tests := []struct {
+		name string
+		// setup prepares dependencies before the first health check as its results are cached.
+		setup  func(h *harness)
+		code   int
+		status routes.HealthzStatus
+		checks map[string]routes.HealthzStatus
+	}{
+		{
+			name:   "healthy",
+			setup:  func(_ *harness) {},
+			code:   fasthttp.StatusOK,
+			status: routes.HealthzPass,
+			checks: map[string]routes.HealthzStatus{
+				"vault:token":       routes.HealthzPass,
+				"vault:secret":      routes.HealthzPass,
+				"csdd:reachability": routes.HealthzPass,
+				"csdd:rotation":     routes.HealthzPass,
+				"csdd:credentials":  routes.HealthzPass,
+				"csdd:sessions":     routes.HealthzPass,
+				"csdd:circuit":      routes.HealthzPass,
+			},
+		},
+		{
+			name: "Vault unavailable with idle sessions",
+			setup: func(h *harness) {
+				resp := h.get("/1.0/mdl")
+				expectStatus(h.t, resp, fasthttp.StatusOK)
+
+				h.vault.AddFault(&vaultmock.Fault{
+					Operation: vaultmock.OperationRead,
+					Status:    fasthttp.StatusServiceUnavailable,
+				})
+			},
+			code:   fasthttp.StatusOK,
+			status: routes.HealthzWarn,
+			checks: map[string]routes.HealthzStatus{
+				"vault:secret":      routes.HealthzFail,
+				"csdd:reachability": routes.HealthzPass,
+			},
+		},
+		{
+			name: "Vault unavailable without idle sessions",
+			setup: func(h *harness) {
+				h.vault.AddFault(&vaultmock.Fault{
+					Operation: vaultmock.OperationRead,
+					Status:    fasthttp.StatusServiceUnavailable,
+				})
+			},
+			code:   fasthttp.StatusServiceUnavailable,
+			status: routes.HealthzFail,
+			checks: map[string]routes.HealthzStatus{
+				"vault:secret": routes.HealthzFail,
+			},
+		},
+		{
+			name: "interrupted rotation",
+			setup: func(h *harness) {
+				h.vault.SetCustomMetadata(secretPath, map[string]string{
+					"rotation_state":        "pending",
+					"rotation_from_version": "0",
+					"rotation_to_version":   "0",
+					"rotation_updated":      time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339),
+				})
+			},
+			code:   fasthttp.StatusOK,
+			status: routes.HealthzWarn,
+			checks: map[string]routes.HealthzStatus{
+				"csdd:rotation":    routes.HealthzFail,
+				"csdd:credentials": routes.HealthzPass,
+			},
+		},
+		{
+			name: "login suspended with idle sessions",
+			setup: func(h *harness) {
+				resp := h.get("/1.0/mdl")
+				expectStatus(h.t, resp, fasthttp.StatusOK)
+
+				h.suspendLogin()
+			},
+			code:   fasthttp.StatusOK,
+			status: routes.HealthzWarn,
+			checks: map[string]routes.HealthzStatus{
+				"csdd:credentials": routes.HealthzFail,
+			},
+		},
+		{
+			name:   "login suspended without idle sessions",
+			setup:  func(h *harness) { h.suspendLogin() },
+			code:   fasthttp.StatusServiceUnavailable,
+			status: routes.HealthzFail,
+			checks: map[string]routes.HealthzStatus{
+				"csdd:credentials": routes.HealthzFail,
+			},
+		},
+		{
+			name: "circuit open",
+			setup: func(h *harness) {
+				h.csdd.AddFault(&csddmock.Fault{
+					Service: "Chk_web_Gliet",
+					Status:  fasthttp.StatusServiceUnavailable,
+				})
+
+				resp := h.get("/1.0/mdl")
+				expectStatus(h.t, resp, fasthttp.StatusServiceUnavailable)
+			},
+			code:   fasthttp.StatusServiceUnavailable,
+			status: routes.HealthzFail,
+			checks: map[string]routes.HealthzStatus{
+				"vault:secret":     routes.HealthzPass,
+				"csdd:credentials": routes.HealthzPass,
+				"csdd:circuit":     routes.HealthzFail,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHarness(t)
+
+			tt.setup(h)
+
+			resp := h.get("/healthz")
+			expectStatus(t, resp, tt.code)
+
+			health := &routes.HealthzResponse{}
+			h.decode(resp, health)
+
+			if health.Status != tt.status {
+				t.Errorf("expected status %q, got %q", tt.status, health.Status)
+			}
+
+			for name, status := range tt.checks {
+				if c := health.Checks[name]; len(c) == 0 || c[0].Status != status {
+					t.Errorf("expected %s check %q, got %v", name, status, c)
+				}
+			}
+		})
+	}
+}
+
+// suspendLogin persists login suspension of the current Vault secret version as if recovery has failed.
+func (h *harness) suspendLogin() {
+	h.vault.SetCustomMetadata(secretPath, map[string]string{
+		"login_suspended_version": strconv.Itoa(h.vault.CurrentVersion(secretPath)),
+		"login_suspended_until":   time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+	})
+}
@@ -0,0 +1,19 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package routes
+
+import (
+	"azugo.io/azugo"
+)
+
+// jwksMaxAge is the time JWKS can be cached by clients, it must be shorter than the time previous key
+// is kept published after key rotation.
+const jwksMaxAge = "max-age=3600"
+
+// jwks publishes public keys to verify signed responses.
+func (r *router) jwks(ctx *azugo.Context) {
+	ctx.SkipRequestLog()
+
+	ctx.Header.Set("Cache-Control", jwksMaxAge)
+	ctx.JSON(r.ResponseSigner().JWKS())
+}
@@ -0,0 +1,144 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package routes_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/valyala/fasthttp"
+)
+
+func TestJWKS(t *testing.T) {
+	previous, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := json.Marshal(jose.JSONWebKey{Key: &previous.PublicKey, KeyID: "previous"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previousFile := filepath.Join(t.TempDir(), "previous.jwk")
+	if err := os.WriteFile(previousFile, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("RESPONSE_SIGNING_PREVIOUS_KEY_FILES", previousFile)
+
+	h := newHarness(t)
+
+	resp := h.get("/.well-known/jwks.json")
+	expectStatus(t, resp, fasthttp.StatusOK)
+
+	jwks := &jose.JSONWebKeySet{}
+	h.decode(resp, jwks)
+
+	if len(jwks.Keys) != 2 {
+		t.Fatalf("expected current and previous keys, got %d keys", len(jwks.Keys))
+	}
+
+	if !jwks.Keys[0].IsPublic() || !h.issuer.PublicKey.(*ecdsa.PublicKey).Equal(jwks.Keys[0].Key) {
+		t.Error("expected public key of response signing key")
+	}
+
+	if keys := jwks.Key("previous"); len(keys) != 1 || !previous.PublicKey.Equal(keys[0].Key) {
+		t.Error("expected previous key to be published")
+	}
+
+	// signed response must be verifiable with published key selected by kid
+	resp = h.get("/1.0/mdl", h.test.TestClient().WithHeader("Accept", "application/jwt"))
+	expectStatus(t, resp, fasthttp.StatusOK)
+
+	jws, err := jose.ParseSigned(string(resp.Body()), []jose.SignatureAlgorithm{jose.ES256})
+	if err != nil {
+		t.Fatalf("failed to parse JWT: %v", err)
+	}
+
+	keys := jwks.Key(jws.Signatures[0].Header.KeyID)
+	if len(keys) != 1 {
+		t.Fatalf("expected key with kid %q in JWKS", jws.Signatures[0].Header.KeyID)
+	}
+
+	if _, err := jws.Verify(keys[0].Key); err != nil {
+		t.Errorf("invalid response signature: %v", err)
+	}
+}
+
+func TestJWKSPreviousPEMKeyID(t *testing.T) {
+	previous, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(&previous.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previousFile := filepath.Join(t.TempDir(), "previous.pem")
+	if err := os.WriteFile(previousFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// previous key was used with RESPONSE_SIGNING_KEY_ID
+	t.Setenv("RESPONSE_SIGNING_PREVIOUS_KEY_FILES", previousFile)
+	t.Setenv("RESPONSE_SIGNING_PREVIOUS_KEY_IDS", "signing-2025")
+
+	h := newHarness(t)
+
+	resp := h.get("/.well-known/jwks.json")
+	expectStatus(t, resp, fasthttp.StatusOK)
+
+	jwks := &jose.JSONWebKeySet{}
+	h.decode(resp, jwks)
+
+	if keys := jwks.Key("signing-2025"); len(keys) != 1 || !previous.PublicKey.Equal(keys[0].Key) {
+		t.Error("expected previous key to be published with configured kid")
+	}
+}
+
+func TestMDLDetachedSignature(t *testing.T) {
+	t.Setenv("RESPONSE_SIGNING_DETACHED", "true")
+
+	h := newHarness(t)
+
+	resp := h.get("/1.0/mdl")
+	expectStatus(t, resp, fasthttp.StatusOK)
+
+	if ct := string(resp.Header.ContentType()); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("expected JSON content type, got %q", ct)
+	}
+
+	signature := string(resp.Header.Peek("X-JWS-Signature"))
+	if !strings.Contains(signature, "..") {
+		t.Fatalf("expected detached JWS in X-JWS-Signature header, got %q", signature)
+	}
+
+	jws, err := jose.ParseDetached(signature, resp.Body(), []jose.SignatureAlgorithm{jose.ES256})
+	if err != nil {
+		t.Fatalf("failed to parse detached JWS: %v", err)
+	}
+
+	if _, err := jws.Verify(h.issuer.PublicKey); err != nil {
+		t.Fatalf("invalid response signature: %v", err)
+	}
+
+	if source := jws.Signatures[0].Protected.ExtraHeaders["source"]; source != "CSDD" {
+		t.Errorf("expected source CSDD in protected header, got %v", source)
+	}
+
+	if jws.Signatures[0].Protected.ExtraHeaders["iat"] == nil {
+		t.Error("expected iat in protected header")
+	}
+}
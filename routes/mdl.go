@@ -3,53 +3,194 @@
 package routes
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"math"
+	"strconv"
+	"strings"
 
+	"git.zzdats.lv/edim/api-mdl/csdd"
 	"git.zzdats.lv/edim/api-mdl/routes/responses"
+	"git.zzdats.lv/edim/api-mdl/signing"
+	"git.zzdats.lv/edim/api-mdl/tracing"
 
 	"azugo.io/azugo"
 	"azugo.io/core/http"
-	"github.com/valyala/fasthttp"
+	"github.com/fxamacker/cbor/v2"
+	"go.uber.org/zap"
 )
 
 // @personId personID
 // @title Get person data from CSDD
-// @description Method return person driver licence data from CSDD
+// @description Method return person driver licence data from CSDD.
+// @description Representation is selected by Accept header: application/json (default), application/cbor with dates
+// @description as full-date (tag 1004) and portrait as bstr, or application/jwt signed with response signing key
+// @description when it is configured. JWT has iat and source claims and can be verified with keys published in
+// @description /.well-known/jwks.json. If detached signing is enabled, JSON response has detached JWS (RFC 7515
+// @description Appendix F) of the response body in X-JWS-Signature header.
+// @description If encryption key is supplied in X-Encryption-Key header or registered for the client, response is
+// @description returned as JWE (application/jose) encrypted with ECDH-ES and A256GCM with the selected representation
+// @description as plaintext and its media type in cty header. Clients configured to require encryption must
+// @description supply or register encryption key.
+// @description Errors are returned as RFC 9457 problem details (application/problem+json) with problem type URI
+// @description and correlation_id that is also returned in X-Request-ID header.
+// @param X-Encryption-Key header string false "Base64url encoded EC public key in JWK format to encrypt response to"
 // @success 200 MDLResponse responses.MDLResponse "Get person data from CSDD"
-// @failure 400 string string "Bad request"
+// @success 200 {file} application/cbor "Get person data from CSDD"
+// @success 200 {file} application/jwt "Get person data from CSDD"
+// @success 200 {file} application/jose "Get person data from CSDD"
+// @failure 400 Problem responses.Problem "urn:problem-type:api-mdl:invalid-request - encryption key is invalid, urn:problem-type:api-mdl:encryption-required - client must receive encrypted response but has no encryption key"
 // @failure 401 {empty} "Unauthorized"
 // @failure 403 {empty} "Forbidden"
-// @failure 404 {empty} "Not found"
-// @failure 500 string string "Internal server error"
+// @failure 404 Problem responses.Problem "urn:problem-type:api-mdl:not-found - driving licence data not found"
+// @failure 406 Problem responses.Problem "urn:problem-type:api-mdl:not-acceptable - none of the media types in Accept header is supported"
+// @failure 500 Problem responses.Problem "urn:problem-type:api-mdl:internal-error - internal server error"
+// @failure 502 Problem responses.Problem "urn:problem-type:api-mdl:register-error - CSDD returned an error"
+// @failure 503 Problem responses.Problem "urn:problem-type:api-mdl:register-unavailable - CSDD or Vault is unavailable, Retry-After header is set while CSDD circuit breaker is open"
 // @route /1.0/mdl [get].
 func (r *router) mdl(ctx *azugo.Context) {
-	csddresult, err := r.CsddService().GetCSDDData(ctx, ctx.User().Claim("code")[0])
+	var err error
+
+	tctx, span := tracing.Start(ctx, "router.mdl")
+	defer tracing.End(span, &err)
+
+	id := correlationID(ctx, span.SpanContext())
+	log := ctx.Log().With(zap.String("correlation_id", id))
+
+	offers := r.mdlContentTypes()
+
+	ctx.Header.Set("Vary", "Accept")
+
+	contentType := negotiate(ctx.Header.Get("Accept"), offers)
+	if contentType == "" {
+		problem(ctx, id, problemNotAcceptable, "Supported media types: "+strings.Join(offers, ", "))
+
+		return
+	}
+
+	data, err := r.mdlData(ctx, tctx, id, log)
+	if err != nil {
+		return
+	}
+
+	if contentType == contentTypeJSON && !r.detachedSignature() {
+		ctx.JSON(data)
+
+		return
+	}
+
+	body, err := r.encodeMDL(ctx, data, contentType)
+	if err != nil {
+		log.Error("Failed to encode driving licence data", zap.String("content_type", contentType), zap.Error(err))
+		problem(ctx, id, problemInternalError, "")
+
+		return
+	}
+
+	ctx.ContentType(contentType)
+	ctx.Raw(body)
+}
+
+// mdlContentTypes returns media types supported by /1.0/mdl in the order of preference.
+func (r *router) mdlContentTypes() []string {
+	offers := []string{contentTypeJSON, contentTypeCBOR}
+	if r.ResponseSigner() != nil {
+		offers = append(offers, contentTypeJWT)
+	}
+
+	return offers
+}
+
+// detachedSignature returns true if JSON responses are signed with detached JWS.
+func (r *router) detachedSignature() bool {
+	return r.ResponseSigner() != nil && r.Config().Signing.Detached
+}
+
+// encodeMDL encodes driving licence data in CBOR, as JWT with data as claims or as JSON with detached
+// JWS set in X-JWS-Signature header.
+func (r *router) encodeMDL(ctx *azugo.Context, data *responses.MDLResponse, contentType string) ([]byte, error) {
+	switch contentType {
+	case contentTypeCBOR:
+		return cbor.Marshal(data)
+	case contentTypeJWT:
+		jwt, err := r.ResponseSigner().Sign(data)
+		if err != nil {
+			return nil, err
+		}
+
+		return []byte(jwt), nil
+	}
+
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := r.ResponseSigner().SignDetached(b)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx.Header.Set(signing.HeaderSignature, signature)
+
+	return b, nil
+}
+
+// mdlData returns driving licence data of the authenticated user from CSDD.
+//
+// CSDD is called with tctx, so that its spans are children of the route span. Writes problem details
+// response and returns error if data can not be returned.
+func (r *router) mdlData(ctx *azugo.Context, tctx context.Context, id string, log *zap.Logger) (*responses.MDLResponse, error) {
+	code := userClaim(ctx, "code")
+	if code == "" {
+		log.Error("Authenticated user does not have personal code claim")
+		problem(ctx, id, problemInternalError, "")
+
+		return nil, errors.New("authenticated user does not have personal code claim")
+	}
+
+	csddresult, err := r.CsddService().GetCSDDData(tctx, code)
 	if err != nil {
 		if errors.Is(err, http.NotFoundError{}) {
-			ctx.StatusCode(fasthttp.StatusNotFound)
-			ctx.Text("Data about drivers licence not found")
+			problem(ctx, id, problemNotFound, "")
 
-			return
+			return nil, err
 		}
 
-		ctx.Text(err.Error())
-		ctx.Error(err)
+		// fail fast without logging every request while CSDD is down
+		if errors.Is(err, csdd.ErrCircuitOpen) {
+			ctx.Header.Set("Retry-After", strconv.Itoa(int(math.Ceil(r.Config().CSDD.BreakerOpenTimeout.Seconds()))))
+			problem(ctx, id, problemRegisterUnavailable, "Requests to driving licence register are suspended after repeated failures, retry later")
 
-		return
+			return nil, err
+		}
+
+		p := csddProblem(err)
+
+		var cerr *csdd.Error
+		if errors.As(err, &cerr) && cerr.Code != "" {
+			log.Warn("CSDD returned error",
+				zap.String("code", cerr.Code),
+				zap.String("message", cerr.Message),
+				zap.String("problem_type", p.URI))
+		} else {
+			log.Error("Failed to get data from CSDD", zap.Error(err), zap.String("problem_type", p.URI))
+		}
+
+		problem(ctx, id, p, "")
+
+		return nil, err
 	}
 
-	// skatamies vai ir atbildē "errors" bloks
-	// ja ir, tad ir atbilde ar http 200, bet ar kļūdu
-	if len(csddresult.Errors) > 0 {
-		ctx.Error(errors.New(csddresult.Errors[0].ClientMessageCode + ": " + csddresult.Errors[0].ClientMessage))
-		ctx.Text(csddresult.Errors[0].ClientMessageCode + ": " + csddresult.Errors[0].ClientMessage)
-		ctx.StatusCode(fasthttp.StatusNotFound)
+	if len(csddresult.Rowset) == 0 {
+		problem(ctx, id, problemNotFound, "")
 
-		return
+		return nil, csdd.ErrNotFound
 	}
 
 	mdlresult := &responses.MDLResponse{}
-	mdlresult.PersonalAdministrativeNumber = ctx.User().Claim("code")[0]
+	mdlresult.PersonalAdministrativeNumber = code
 	mdlresult.DocumentNumber = csddresult.Rowset[0].DocumentNumber
 	mdlresult.BirthDate = csddresult.Rowset[0].BirthDate
 	mdlresult.GivenName = csddresult.Rowset[0].GivenName
@@ -62,5 +203,5 @@ func (r *router) mdl(ctx *azugo.Context) {
 	mdlresult.Portrait = csddresult.Rowset[0].Portrait
 	mdlresult.DrivingPrivileges = csddresult.Rowset[0].DrivingPrivileges
 
-	ctx.JSON(mdlresult)
+	return mdlresult, nil
 }
@@ -0,0 +1,300 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package routes_test
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"git.zzdats.lv/edim/api-mdl/csdd/csddmock"
+	"git.zzdats.lv/edim/api-mdl/routes/responses"
+	"git.zzdats.lv/edim/api-mdl/vault/vaultmock"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestMDL(t *testing.T) {
+	h := newHarness(t)
+
+	resp := h.get("/1.0/mdl")
+	expectStatus(t, resp, fasthttp.StatusOK)
+
+	mdl := &responses.MDLResponse{}
+	h.decode(resp, mdl)
+
+	if mdl.PersonalAdministrativeNumber != testPerson {
+		t.Errorf("expected personal administrative number %q, got %q", testPerson, mdl.PersonalAdministrativeNumber)
+	}
+
+	if mdl.DocumentNumber != "AA0000001" {
+		t.Errorf("expected document number %q, got %q", "AA0000001", mdl.DocumentNumber)
+	}
+
+	if mdl.GivenName != "JĀNIS" || mdl.FamilyName != "BĒRZIŅŠ" {
+		t.Errorf("unexpected name %q %q", mdl.GivenName, mdl.FamilyName)
+	}
+
+	if mdl.Portrait == "" {
+		t.Error("expected portrait")
+	}
+
+	if len(mdl.DrivingPrivileges) != 2 {
+		t.Fatalf("expected 2 driving privileges, got %d", len(mdl.DrivingPrivileges))
+	}
+
+	if mdl.DrivingPrivileges[0].VehicleCategoryCode != "B" {
+		t.Errorf("expected vehicle category %q, got %q", "B", mdl.DrivingPrivileges[0].VehicleCategoryCode)
+	}
+}
+
+func TestMDLNotFound(t *testing.T) {
+	h := newHarness(t)
+	h.person = "39999999999"
+
+	resp := h.get("/1.0/mdl")
+	expectStatus(t, resp, fasthttp.StatusNotFound)
+
+	p := h.problem(resp, "urn:problem-type:api-mdl:not-found")
+
+	if p.CorrelationID == "" || p.CorrelationID != string(resp.Header.Peek("X-Request-ID")) {
+		t.Errorf("expected correlation ID to match X-Request-ID header, got %q", p.CorrelationID)
+	}
+}
+
+func TestMDLNotFoundReusedSession(t *testing.T) {
+	h := newHarness(t)
+
+	resp := h.get("/1.0/mdl")
+	expectStatus(t, resp, fasthttp.StatusOK)
+
+	h.person = "39999999999"
+
+	// not found error on reused session must not be retried
+	for range 2 {
+		queries := h.csdd.Calls("Qry_va")
+
+		resp = h.get("/1.0/mdl")
+		expectStatus(t, resp, fasthttp.StatusNotFound)
+
+		h.problem(resp, "urn:problem-type:api-mdl:not-found")
+
+		if calls := h.csdd.Calls("Qry_va") - queries; calls != 1 {
+			t.Errorf("expected 1 CSDD query, got %d", calls)
+		}
+	}
+}
+
+func TestMDLSessionExpired(t *testing.T) {
+	h := newHarness(t)
+
+	resp := h.get("/1.0/mdl")
+	expectStatus(t, resp, fasthttp.StatusOK)
+
+	logins := h.csdd.Calls("Chk_web_Gliet")
+
+	// expired session is detected by the default session expired code
+	h.csdd.ExpireSessions()
+
+	resp = h.get("/1.0/mdl")
+	expectStatus(t, resp, fasthttp.StatusOK)
+
+	if calls := h.csdd.Calls("Chk_web_Gliet") - logins; calls != 1 {
+		t.Errorf("expected 1 new login, got %d", calls)
+	}
+}
+
+func TestMDLAccountLocked(t *testing.T) {
+	h := newHarness(t)
+
+	h.csdd.AddFault(&csddmock.Fault{
+		Service: "Chk_web_Gliet",
+		Times:   1,
+		Error: &responses.ErrorResponse{
+			ClientMessageCode: csddmock.CodeAccountLocked,
+			ClientMessage:     "Lietotājs bloķēts",
+		},
+	})
+
+	resp := h.get("/1.0/mdl")
+	expectStatus(t, resp, fasthttp.StatusServiceUnavailable)
+
+	h.problem(resp, "urn:problem-type:api-mdl:register-account-locked")
+}
+
+func TestMDLCSDDError(t *testing.T) {
+	h := newHarness(t)
+
+	h.csdd.AddFault(&csddmock.Fault{
+		Service: "Qry_va",
+		Person:  testPerson,
+		Times:   1,
+		Error: &responses.ErrorResponse{
+			ClientMessageCode: "F-00123",
+			ClientMessage:     "Dati nav pieejami",
+		},
+	})
+
+	resp := h.get("/1.0/mdl")
+	expectStatus(t, resp, fasthttp.StatusBadGateway)
+
+	h.problem(resp, "urn:problem-type:api-mdl:register-error")
+
+	if strings.Contains(string(resp.Body()), "F-00123") || strings.Contains(string(resp.Body()), "Dati nav pieejami") {
+		t.Errorf("expected CSDD error not to be leaked, got %q", resp.Body())
+	}
+
+	resp = h.get("/1.0/mdl")
+	expectStatus(t, resp, fasthttp.StatusOK)
+}
+
+func TestMDLRecoversWrongPassword(t *testing.T) {
+	h := newHarness(t)
+
+	// latest password in Vault is not accepted by CSDD
+	h.vault.Put(secretPath, map[string]any{passwordKey: "Wrong-Parole-2025"})
+
+	resp := h.get("/1.0/mdl")
+	expectStatus(t, resp, fasthttp.StatusOK)
+
+	// recovered password is saved to Vault in background
+	h.eventually("recovered password in Vault", func() bool {
+		return h.vault.CurrentVersion(secretPath) == 3
+	})
+
+	if psw := h.vaultPassword(0); psw != testPassword {
+		t.Errorf("expected recovered password %q in Vault, got %q", testPassword, psw)
+	}
+
+	h.csdd.ExpireSessions()
+
+	resp = h.get("/1.0/mdl")
+	expectStatus(t, resp, fasthttp.StatusOK)
+}
+
+func TestMDLRecoverySuspendedByOtherInstance(t *testing.T) {
+	h := newHarness(t)
+
+	version := h.vault.Put(secretPath, map[string]any{passwordKey: "Wrong-Parole-2025"})
+
+	// recovery of the latest version has failed on other instance
+	h.vault.SetCustomMetadata(secretPath, map[string]string{
+		"login_suspended_version": strconv.Itoa(version),
+		"login_suspended_until":   time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+	})
+
+	h.csdd.ExpireSessions()
+
+	logins := h.csdd.Calls("Chk_web_Gliet")
+
+	resp := h.get("/1.0/mdl")
+	expectStatus(t, resp, fasthttp.StatusServiceUnavailable)
+	h.problem(resp, "urn:problem-type:api-mdl:register-unavailable")
+
+	if calls := h.csdd.Calls("Chk_web_Gliet"); calls != logins {
+		t.Errorf("expected no CSDD login attempts while suspended, got %d", calls-logins)
+	}
+}
+
+func TestMDLRotatesExpiredPassword(t *testing.T) {
+	h := newHarness(t)
+
+	h.csdd.SetPM(testUser, 1)
+
+	resp := h.get("/1.0/mdl")
+	expectStatus(t, resp, fasthttp.StatusOK)
+
+	h.eventually("password rotation", func() bool {
+		return h.vault.CustomMetadata(secretPath)["rotation_result"] == "rotated"
+	})
+
+	psw := h.csdd.Password(testUser)
+	if psw == testPassword {
+		t.Fatal("expected CSDD password to be changed")
+	}
+
+	if vpsw := h.vaultPassword(0); vpsw != psw {
+		t.Errorf("expected Vault password to match CSDD password %q, got %q", psw, vpsw)
+	}
+
+	resp = h.get("/1.0/mdl")
+	expectStatus(t, resp, fasthttp.StatusOK)
+}
+
+func TestMDLVaultTokenExpired(t *testing.T) {
+	h := newHarness(t)
+
+	resp := h.get("/1.0/mdl")
+	expectStatus(t, resp, fasthttp.StatusOK)
+
+	logins := h.vault.Requests("login")
+
+	// force both new CSDD login and Vault read with the expired token
+	h.vault.ExpireTokens()
+	h.csdd.ExpireSessions()
+
+	resp = h.get("/1.0/mdl")
+	expectStatus(t, resp, fasthttp.StatusOK)
+
+	if h.vault.Requests("login") <= logins {
+		t.Error("expected Vault token to be renewed")
+	}
+}
+
+func TestMDLVaultTokenRejected(t *testing.T) {
+	h := newHarness(t)
+
+	reads := h.vault.Requests(vaultmock.OperationRead)
+	logins := h.vault.Requests(vaultmock.OperationLogin)
+
+	h.vault.AddFault(&vaultmock.Fault{
+		Operation:    vaultmock.OperationRead,
+		InvalidToken: true,
+	})
+
+	resp := h.get("/1.0/mdl")
+	expectStatus(t, resp, fasthttp.StatusServiceUnavailable)
+
+	if n := h.vault.Requests(vaultmock.OperationRead) - reads; n != 3 {
+		t.Errorf("expected 3 Vault read attempts, got %d", n)
+	}
+
+	// token is not renewed after the last attempt
+	if n := h.vault.Requests(vaultmock.OperationLogin) - logins; n != 2 {
+		t.Errorf("expected 2 Vault logins, got %d", n)
+	}
+}
+
+func TestRotationRollback(t *testing.T) {
+	h := newHarness(t)
+
+	h.csdd.AddFault(&csddmock.Fault{
+		Service: "Upd_web_parole",
+		Times:   1,
+		Error: &responses.ErrorResponse{
+			ClientMessageCode: "F-00050",
+			ClientMessage:     "Parole neatbilst prasībām",
+		},
+	})
+
+	if _, err := h.app.CsddService().RotatePassword(context.Background()); err == nil {
+		t.Fatal("expected rotation to fail")
+	}
+
+	if psw := h.csdd.Password(testUser); psw != testPassword {
+		t.Errorf("expected CSDD password to stay %q, got %q", testPassword, psw)
+	}
+
+	if psw := h.vaultPassword(0); psw != testPassword {
+		t.Errorf("expected Vault password to be rolled back to %q, got %q", testPassword, psw)
+	}
+
+	if result := h.vault.CustomMetadata(secretPath)["rotation_result"]; result != "rolled_back" {
+		t.Errorf("expected rotation result %q, got %q", "rolled_back", result)
+	}
+
+	resp := h.get("/1.0/mdl")
+	expectStatus(t, resp, fasthttp.StatusOK)
+}
@@ -0,0 +1,84 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package routes
+
+import (
+	"errors"
+
+	"git.zzdats.lv/edim/api-mdl/mdoc"
+	"git.zzdats.lv/edim/api-mdl/routes/requests"
+	"git.zzdats.lv/edim/api-mdl/tracing"
+
+	"azugo.io/azugo"
+	"go.uber.org/zap"
+)
+
+// @personId personID
+// @title Issue driving licence mdoc
+// @description Method returns person driver licence data from CSDD as ISO/IEC 18013-5 IssuerSigned structure
+// @description (application/cbor) in org.iso.18013.5.1 namespace, signed by document signer and bound to the holder device key.
+// @description Available only when mdoc issuer key is configured.
+// @param MDocRequest body requests.MDocRequest true "Holder device public key"
+// @success 200 {file} application/cbor "CBOR encoded IssuerSigned"
+// @failure 400 Problem responses.Problem "urn:problem-type:api-mdl:invalid-request - device key is missing or invalid"
+// @failure 401 {empty} "Unauthorized"
+// @failure 403 {empty} "Forbidden"
+// @failure 404 Problem responses.Problem "urn:problem-type:api-mdl:not-found - driving licence data not found"
+// @failure 422 Problem responses.Problem "urn:problem-type:api-mdl:licence-expired - driving licence has expired"
+// @failure 500 Problem responses.Problem "urn:problem-type:api-mdl:internal-error - internal server error"
+// @failure 502 Problem responses.Problem "urn:problem-type:api-mdl:register-error - CSDD returned an error or incomplete driving licence data"
+// @failure 503 Problem responses.Problem "urn:problem-type:api-mdl:register-unavailable - CSDD or Vault is unavailable, Retry-After header is set while CSDD circuit breaker is open"
+// @route /1.0/mdl/mdoc [post].
+func (r *router) mdoc(ctx *azugo.Context) {
+	var err error
+
+	tctx, span := tracing.Start(ctx, "router.mdoc")
+	defer tracing.End(span, &err)
+
+	id := correlationID(ctx, span.SpanContext())
+	log := ctx.Log().With(zap.String("correlation_id", id))
+
+	req := &requests.MDocRequest{}
+	if err := ctx.Body.JSON(req); err != nil {
+		problem(ctx, id, problemInvalidRequest, "Request body must be JSON object with device_key")
+
+		return
+	}
+
+	deviceKey, err := mdoc.DeviceKey(req.DeviceKey)
+	if err != nil {
+		problem(ctx, id, problemInvalidRequest, "device_key must be EC public key in JWK format on P-256, P-384 or P-521 curve")
+
+		return
+	}
+
+	data, err := r.mdlData(ctx, tctx, id, log)
+	if err != nil {
+		return
+	}
+
+	doc, err := r.MDocIssuer().Issue(data, deviceKey)
+	if err != nil {
+		if errors.Is(err, mdoc.ErrExpired) {
+			problem(ctx, id, problemLicenceExpired, "Mdoc can not be issued for expired driving licence")
+
+			return
+		}
+
+		if errors.Is(err, mdoc.ErrIncompleteData) {
+			log.Warn("CSDD returned incomplete driving licence data", zap.Error(err))
+			problem(ctx, id, problemRegisterError, "")
+
+			return
+		}
+
+		log.Error("Failed to issue mdoc", zap.Error(err))
+		problem(ctx, id, problemInternalError, "")
+
+		return
+	}
+
+	ctx.Header.Set(headerRequestID, id)
+	ctx.ContentType(mdoc.ContentType)
+	ctx.Raw(doc)
+}
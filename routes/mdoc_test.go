@@ -0,0 +1,209 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package routes_test
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"math/big"
+	"testing"
+	"time"
+
+	"git.zzdats.lv/edim/api-mdl/routes/requests"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/valyala/fasthttp"
+)
+
+type testIssuerSigned struct {
+	NameSpaces map[string][]cbor.RawMessage `cbor:"nameSpaces"`
+	IssuerAuth testSign1                    `cbor:"issuerAuth"`
+}
+
+type testSign1 struct {
+	_           struct{} `cbor:",toarray"`
+	Protected   []byte
+	Unprotected map[int]cbor.RawMessage
+	Payload     []byte
+	Signature   []byte
+}
+
+type testMSO struct {
+	DigestAlgorithm string                       `cbor:"digestAlgorithm"`
+	ValueDigests    map[string]map[uint64][]byte `cbor:"valueDigests"`
+	DeviceKeyInfo   struct {
+		DeviceKey map[int]any `cbor:"deviceKey"`
+	} `cbor:"deviceKeyInfo"`
+	DocType      string `cbor:"docType"`
+	ValidityInfo struct {
+		ValidFrom  time.Time `cbor:"validFrom"`
+		ValidUntil time.Time `cbor:"validUntil"`
+	} `cbor:"validityInfo"`
+}
+
+type testItem struct {
+	DigestID          uint64 `cbor:"digestID"`
+	Random            []byte `cbor:"random"`
+	ElementIdentifier string `cbor:"elementIdentifier"`
+	ElementValue      any    `cbor:"elementValue"`
+}
+
+// unwrap decodes tag 24 encoded CBOR data item into v.
+func unwrap(t *testing.T, data []byte, v any) {
+	t.Helper()
+
+	tag := cbor.Tag{}
+	if err := cbor.Unmarshal(data, &tag); err != nil || tag.Number != 24 {
+		t.Fatalf("expected tag 24 encoded CBOR, got %v: %v", tag.Number, err)
+	}
+
+	content, _ := tag.Content.([]byte)
+	if err := cbor.Unmarshal(content, v); err != nil {
+		t.Fatalf("failed to decode tagged CBOR: %v", err)
+	}
+}
+
+func TestMDoc(t *testing.T) {
+	h := newHarness(t)
+
+	device, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	point := device.PublicKey().Bytes()
+
+	resp := h.post("/1.0/mdl/mdoc", &requests.MDocRequest{
+		DeviceKey: &requests.JWK{
+			Kty: "EC",
+			Crv: "P-256",
+			X:   base64.RawURLEncoding.EncodeToString(point[1:33]),
+			Y:   base64.RawURLEncoding.EncodeToString(point[33:]),
+		},
+	})
+	expectStatus(t, resp, fasthttp.StatusOK)
+
+	if ct := string(resp.Header.ContentType()); ct != "application/cbor" {
+		t.Errorf("expected CBOR content type, got %q", ct)
+	}
+
+	doc := &testIssuerSigned{}
+	if err := cbor.Unmarshal(resp.Body(), doc); err != nil {
+		t.Fatalf("failed to decode IssuerSigned: %v", err)
+	}
+
+	// verify COSE_Sign1 signature with document signer certificate
+	auth := doc.IssuerAuth
+
+	var x5chain []byte
+	if err := cbor.Unmarshal(auth.Unprotected[33], &x5chain); err != nil || !bytes.Equal(x5chain, h.issuer.Raw) {
+		t.Fatalf("expected document signer certificate in x5chain: %v", err)
+	}
+
+	toBeSigned, err := cbor.Marshal([]any{"Signature1", auth.Protected, []byte{}, auth.Payload})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest := sha256.Sum256(toBeSigned)
+	r := new(big.Int).SetBytes(auth.Signature[:32])
+	s := new(big.Int).SetBytes(auth.Signature[32:])
+
+	if !ecdsa.Verify(h.issuer.PublicKey.(*ecdsa.PublicKey), digest[:], r, s) {
+		t.Fatal("invalid issuer signature")
+	}
+
+	mso := &testMSO{}
+	unwrap(t, auth.Payload, mso)
+
+	if mso.DocType != "org.iso.18013.5.1.mDL" || mso.DigestAlgorithm != "SHA-256" {
+		t.Errorf("unexpected docType %q or digest algorithm %q", mso.DocType, mso.DigestAlgorithm)
+	}
+
+	if x, _ := mso.DeviceKeyInfo.DeviceKey[-2].([]byte); !bytes.Equal(x, point[1:33]) {
+		t.Error("expected mdoc to be bound to the device key")
+	}
+
+	if !mso.ValidityInfo.ValidUntil.After(mso.ValidityInfo.ValidFrom) {
+		t.Errorf("invalid validity %s - %s", mso.ValidityInfo.ValidFrom, mso.ValidityInfo.ValidUntil)
+	}
+
+	// verify value digests of all data elements
+	digests := mso.ValueDigests["org.iso.18013.5.1"]
+	values := make(map[string]any)
+
+	for _, raw := range doc.NameSpaces["org.iso.18013.5.1"] {
+		item := &testItem{}
+		unwrap(t, raw, item)
+
+		d := sha256.Sum256(raw)
+		if !bytes.Equal(digests[item.DigestID], d[:]) {
+			t.Errorf("value digest of %s does not match", item.ElementIdentifier)
+		}
+
+		if len(item.Random) < 16 {
+			t.Errorf("expected at least 16 byte salt for %s", item.ElementIdentifier)
+		}
+
+		values[item.ElementIdentifier] = item.ElementValue
+	}
+
+	if len(values) != len(digests) {
+		t.Errorf("expected %d data elements, got %d", len(digests), len(values))
+	}
+
+	if values["family_name"] != "BĒRZIŅŠ" || values["document_number"] != "AA0000001" {
+		t.Errorf("unexpected family name %v or document number %v", values["family_name"], values["document_number"])
+	}
+
+	if birth, ok := values["birth_date"].(cbor.Tag); !ok || birth.Number != 1004 || birth.Content != "1985-04-12" {
+		t.Errorf("expected birth date as full-date, got %v", values["birth_date"])
+	}
+
+	if _, ok := values["portrait"].([]byte); !ok {
+		t.Errorf("expected portrait as bstr, got %T", values["portrait"])
+	}
+}
+
+func TestMDocInvalidDeviceKey(t *testing.T) {
+	h := newHarness(t)
+
+	resp := h.post("/1.0/mdl/mdoc", &requests.MDocRequest{
+		DeviceKey: &requests.JWK{Kty: "EC", Crv: "P-256", X: "AAAA", Y: "AAAA"},
+	})
+	expectStatus(t, resp, fasthttp.StatusBadRequest)
+
+	h.problem(resp, "urn:problem-type:api-mdl:invalid-request")
+
+	if calls := h.csdd.Calls("Qry_va"); calls != 0 {
+		t.Errorf("expected CSDD not to be called, got %d calls", calls)
+	}
+}
+
+func TestMDocExpiredLicence(t *testing.T) {
+	h := newHarness(t)
+	h.person = "32000000003"
+
+	device, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	point := device.PublicKey().Bytes()
+
+	resp := h.post("/1.0/mdl/mdoc", &requests.MDocRequest{
+		DeviceKey: &requests.JWK{
+			Kty: "EC",
+			Crv: "P-256",
+			X:   base64.RawURLEncoding.EncodeToString(point[1:33]),
+			Y:   base64.RawURLEncoding.EncodeToString(point[33:]),
+		},
+	})
+	expectStatus(t, resp, fasthttp.StatusUnprocessableEntity)
+
+	h.problem(resp, "urn:problem-type:api-mdl:licence-expired")
+}
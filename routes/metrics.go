@@ -0,0 +1,66 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package routes
+
+import (
+	"bytes"
+	"time"
+
+	"git.zzdats.lv/edim/api-mdl/metrics"
+
+	"azugo.io/azugo"
+	"github.com/prometheus/common/expfmt"
+)
+
+// metrics exposes service metrics in Prometheus text format.
+func (r *router) metrics(ctx *azugo.Context) {
+	ctx.SkipRequestLog()
+
+	families, err := metrics.Registry.Gather()
+	if err != nil {
+		ctx.Error(err)
+
+		return
+	}
+
+	var buf bytes.Buffer
+
+	format := expfmt.NewFormat(expfmt.TypeTextPlain)
+	enc := expfmt.NewEncoder(&buf, format)
+
+	for _, mf := range families {
+		if err := enc.Encode(mf); err != nil {
+			ctx.Error(err)
+
+			return
+		}
+	}
+
+	ctx.Header.Set("Cache-Control", "no-cache")
+	ctx.Header.Set("Content-Type", string(format))
+	ctx.Raw(buf.Bytes())
+}
+
+// mdlRoutes are the MDL API routes observed by their path, other paths are observed as "other"
+// to keep the number of metric series bounded.
+var mdlRoutes = map[string]bool{
+	"/1.0/mdl":        true,
+	"/1.0/mdl/mdoc":   true,
+	"/1.0/mdl/sd-jwt": true,
+}
+
+// observeMDL records result of every MDL API request by route, including the ones rejected by authentication.
+func observeMDL(next azugo.RequestHandler) azugo.RequestHandler {
+	return func(ctx *azugo.Context) {
+		start := time.Now()
+
+		next(ctx)
+
+		route := string(ctx.Context().Path())
+		if !mdlRoutes[route] {
+			route = "other"
+		}
+
+		metrics.ObserveMDL(route, ctx.Context().Response.StatusCode(), start)
+	}
+}
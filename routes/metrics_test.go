@@ -0,0 +1,25 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package routes_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestMetricsMDLRoute(t *testing.T) {
+	h := newHarness(t)
+
+	resp := h.get("/1.0/mdl")
+	expectStatus(t, resp, fasthttp.StatusOK)
+
+	resp = h.get("/metrics")
+	expectStatus(t, resp, fasthttp.StatusOK)
+
+	series := `api_mdl_mdl_requests_total{route="/1.0/mdl",status="200"}`
+	if !strings.Contains(string(resp.Body()), series) {
+		t.Errorf("expected %s in metrics", series)
+	}
+}
@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package routes
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	contentTypeJSON = "application/json"
+	contentTypeCBOR = "application/cbor"
+	contentTypeJWT  = "application/jwt"
+)
+
+// negotiate returns the media type from offers that is most preferred by the Accept header.
+//
+// Returns the first offer if Accept header is not set and empty string if none of the offers is acceptable.
+func negotiate(accept string, offers []string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+
+	if strings.TrimSpace(accept) == "" {
+		return offers[0]
+	}
+
+	type mediaRange struct {
+		value string
+		q     float64
+	}
+
+	ranges := make([]mediaRange, 0, 4)
+	// rejected are media types explicitly excluded with q=0
+	rejected := make(map[string]bool)
+
+	for _, r := range strings.Split(accept, ",") {
+		params := strings.Split(r, ";")
+		mr := mediaRange{value: strings.ToLower(strings.TrimSpace(params[0])), q: 1}
+
+		for _, p := range params[1:] {
+			k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+			if strings.EqualFold(strings.TrimSpace(k), "q") {
+				if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+					mr.q = f
+				}
+			}
+		}
+
+		if mr.q <= 0 {
+			rejected[mr.value] = true
+
+			continue
+		}
+
+		ranges = append(ranges, mr)
+	}
+
+	best, bestQ, bestSpecificity := "", 0.0, -1
+
+	for _, r := range ranges {
+		for _, offer := range offers {
+			specificity := match(r.value, offer)
+			if specificity < 0 || rejected[offer] {
+				continue
+			}
+
+			// prefer higher quality, then more specific media range, then order of offers
+			if r.q > bestQ || (r.q == bestQ && specificity > bestSpecificity) {
+				best, bestQ, bestSpecificity = offer, r.q, specificity
+			}
+
+			break
+		}
+	}
+
+	return best
+}
+
+// match returns specificity of the media range matching the media type or -1 if it does not match.
+func match(mediaRange, mediaType string) int {
+	if mediaRange == "*/*" {
+		return 0
+	}
+
+	if mediaRange == mediaType {
+		return 2
+	}
+
+	if typ, sub, _ := strings.Cut(mediaRange, "/"); sub == "*" && strings.HasPrefix(mediaType, typ+"/") {
+		return 1
+	}
+
+	return -1
+}
@@ -0,0 +1,135 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package routes_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/valyala/fasthttp"
+)
+
+func TestMDLCBOR(t *testing.T) {
+	h := newHarness(t)
+
+	resp := h.get("/1.0/mdl", h.test.TestClient().WithHeader("Accept", "application/cbor"))
+	expectStatus(t, resp, fasthttp.StatusOK)
+
+	if ct := string(resp.Header.ContentType()); ct != "application/cbor" {
+		t.Errorf("expected CBOR content type, got %q", ct)
+	}
+
+	if vary := string(resp.Header.Peek("Vary")); vary != "Accept, X-Encryption-Key" {
+		t.Errorf("expected Vary: Accept, X-Encryption-Key, got %q", vary)
+	}
+
+	mdl := make(map[string]any)
+	if err := cbor.Unmarshal(resp.Body(), &mdl); err != nil {
+		t.Fatalf("failed to decode CBOR: %v", err)
+	}
+
+	if mdl["document_number"] != "AA0000001" {
+		t.Errorf("expected document number %q, got %v", "AA0000001", mdl["document_number"])
+	}
+
+	if birth, ok := mdl["birth_date"].(cbor.Tag); !ok || birth.Number != 1004 || birth.Content != "1985-04-12" {
+		t.Errorf("expected birth date as full-date, got %v", mdl["birth_date"])
+	}
+
+	if _, ok := mdl["portrait"].([]byte); !ok {
+		t.Errorf("expected portrait as bstr, got %T", mdl["portrait"])
+	}
+
+	privileges, _ := mdl["driving_privileges"].([]any)
+	if len(privileges) != 2 {
+		t.Fatalf("expected 2 driving privileges, got %v", mdl["driving_privileges"])
+	}
+
+	if p, _ := privileges[0].(map[any]any); p == nil {
+		t.Errorf("expected driving privilege map, got %T", privileges[0])
+	} else if issue, ok := p["issue_date"].(cbor.Tag); !ok || issue.Number != 1004 {
+		t.Errorf("expected driving privilege issue date as full-date, got %v", p["issue_date"])
+	}
+}
+
+func TestMDLCBORPortraitEncoding(t *testing.T) {
+	h := newHarness(t)
+
+	// CSDD may return portrait in URL-safe alphabet without padding
+	portrait := []byte{0xff, 0xd8, 0xff, 0xfb, 0xef}
+
+	person := h.csdd.Person(testPerson)
+	person.Portrait = base64.RawURLEncoding.EncodeToString(portrait)
+	h.csdd.SetPerson(person)
+
+	resp := h.get("/1.0/mdl", h.test.TestClient().WithHeader("Accept", "application/cbor"))
+	expectStatus(t, resp, fasthttp.StatusOK)
+
+	mdl := make(map[string]any)
+	if err := cbor.Unmarshal(resp.Body(), &mdl); err != nil {
+		t.Fatalf("failed to decode CBOR: %v", err)
+	}
+
+	if b, _ := mdl["portrait"].([]byte); !bytes.Equal(b, portrait) {
+		t.Errorf("expected portrait %x, got %x", portrait, b)
+	}
+}
+
+func TestMDLJWT(t *testing.T) {
+	h := newHarness(t)
+
+	resp := h.get("/1.0/mdl", h.test.TestClient().WithHeader("Accept", "application/jwt, application/json;q=0.5"))
+	expectStatus(t, resp, fasthttp.StatusOK)
+
+	if ct := string(resp.Header.ContentType()); ct != "application/jwt" {
+		t.Errorf("expected JWT content type, got %q", ct)
+	}
+
+	jws, err := jose.ParseSigned(string(resp.Body()), []jose.SignatureAlgorithm{jose.ES256})
+	if err != nil {
+		t.Fatalf("failed to parse JWT: %v", err)
+	}
+
+	payload, err := jws.Verify(h.issuer.PublicKey)
+	if err != nil {
+		t.Fatalf("invalid response signature: %v", err)
+	}
+
+	claims := make(map[string]any)
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		t.Fatal(err)
+	}
+
+	if claims["personal_administrative_number"] != testPerson || claims["iat"] == nil || claims["source"] != "CSDD" {
+		t.Errorf("unexpected claims %v", claims)
+	}
+}
+
+func TestMDLAcceptWildcard(t *testing.T) {
+	h := newHarness(t)
+
+	resp := h.get("/1.0/mdl", h.test.TestClient().WithHeader("Accept", "text/html, */*;q=0.1"))
+	expectStatus(t, resp, fasthttp.StatusOK)
+
+	if ct := string(resp.Header.ContentType()); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("expected JSON content type, got %q", ct)
+	}
+}
+
+func TestMDLNotAcceptable(t *testing.T) {
+	h := newHarness(t)
+
+	resp := h.get("/1.0/mdl", h.test.TestClient().WithHeader("Accept", "text/html, application/json;q=0"))
+	expectStatus(t, resp, fasthttp.StatusNotAcceptable)
+
+	h.problem(resp, "urn:problem-type:api-mdl:not-acceptable")
+
+	if calls := h.csdd.Calls("Qry_va"); calls != 0 {
+		t.Errorf("expected CSDD not to be called, got %d calls", calls)
+	}
+}
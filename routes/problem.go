@@ -0,0 +1,148 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package routes
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"regexp"
+
+	"git.zzdats.lv/edim/api-mdl/csdd"
+	"git.zzdats.lv/edim/api-mdl/routes/responses"
+
+	"azugo.io/azugo"
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+const (
+	// problemTypeBase is the prefix of problem type URIs.
+	problemTypeBase = "urn:problem-type:api-mdl:"
+
+	contentTypeProblem = "application/problem+json"
+
+	headerRequestID = "X-Request-ID"
+)
+
+// problemType is the RFC 9457 problem type returned to clients.
+//
+// URI must never change once published as clients use it to identify the problem.
+type problemType struct {
+	URI    string
+	Title  string
+	Status int
+}
+
+var (
+	problemNotFound = &problemType{
+		URI:    problemTypeBase + "not-found",
+		Title:  "Driving licence data not found",
+		Status: fasthttp.StatusNotFound,
+	}
+	problemRegisterError = &problemType{
+		URI:    problemTypeBase + "register-error",
+		Title:  "Driving licence register returned an error",
+		Status: fasthttp.StatusBadGateway,
+	}
+	problemRegisterUnavailable = &problemType{
+		URI:    problemTypeBase + "register-unavailable",
+		Title:  "Driving licence register is unavailable",
+		Status: fasthttp.StatusServiceUnavailable,
+	}
+	problemAccountLocked = &problemType{
+		URI:    problemTypeBase + "register-account-locked",
+		Title:  "Driving licence register service account is locked",
+		Status: fasthttp.StatusServiceUnavailable,
+	}
+	problemInvalidRequest = &problemType{
+		URI:    problemTypeBase + "invalid-request",
+		Title:  "Invalid request",
+		Status: fasthttp.StatusBadRequest,
+	}
+	problemEncryptionRequired = &problemType{
+		URI:    problemTypeBase + "encryption-required",
+		Title:  "Response encryption key is required",
+		Status: fasthttp.StatusBadRequest,
+	}
+	problemNotAcceptable = &problemType{
+		URI:    problemTypeBase + "not-acceptable",
+		Title:  "Requested media type is not supported",
+		Status: fasthttp.StatusNotAcceptable,
+	}
+	problemLicenceExpired = &problemType{
+		URI:    problemTypeBase + "licence-expired",
+		Title:  "Driving licence has expired",
+		Status: fasthttp.StatusUnprocessableEntity,
+	}
+	problemInternalError = &problemType{
+		URI:    problemTypeBase + "internal-error",
+		Title:  "Internal server error",
+		Status: fasthttp.StatusInternalServerError,
+	}
+)
+
+// csddProblems maps CSDD error classes to problem types.
+//
+// Errors that are not CSDD errors are reported as problemRegisterUnavailable.
+var csddProblems = map[error]*problemType{
+	csdd.ErrNotFound:        problemNotFound,
+	csdd.ErrSessionExpired:  problemRegisterUnavailable,
+	csdd.ErrInvalidPassword: problemRegisterUnavailable,
+	csdd.ErrAccountLocked:   problemAccountLocked,
+	csdd.ErrUnavailable:     problemRegisterUnavailable,
+	csdd.ErrUnknown:         problemRegisterError,
+}
+
+// csddProblem returns problem type for the error returned by CSDD service.
+func csddProblem(err error) *problemType {
+	var cerr *csdd.Error
+	if errors.As(err, &cerr) {
+		if p, ok := csddProblems[cerr.Class]; ok {
+			return p
+		}
+	}
+
+	return problemRegisterUnavailable
+}
+
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// correlationID returns request ID provided by the client, trace ID of the request or a new random ID.
+func correlationID(ctx *azugo.Context, sc trace.SpanContext) string {
+	if id := ctx.Header.Get(headerRequestID); requestIDPattern.MatchString(id) {
+		return id
+	}
+
+	if sc.HasTraceID() {
+		return sc.TraceID().String()
+	}
+
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+// problem writes problem details response.
+//
+// Detail must not contain internal error messages as it is returned to the client.
+func problem(ctx *azugo.Context, id string, p *problemType, detail string) {
+	body, err := json.Marshal(&responses.Problem{
+		Type:          p.URI,
+		Title:         p.Title,
+		Status:        p.Status,
+		Detail:        detail,
+		Instance:      ctx.Path(),
+		CorrelationID: id,
+	})
+	if err != nil {
+		ctx.Log().Error("Failed to encode problem details", zap.Error(err))
+	}
+
+	ctx.Header.Set(headerRequestID, id)
+	ctx.StatusCode(p.Status).ContentType(contentTypeProblem)
+	ctx.Raw(body)
+}
@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package routes
+
+import (
+	"context"
+	"errors"
+
+	"azugo.io/azugo"
+	"github.com/valyala/fasthttp"
+)
+
+func (r *router) livez(ctx *azugo.Context) {
+	ctx.SkipRequestLog()
+
+	ctx.Header.Set("Cache-Control", "no-cache")
+	ctx.JSON(&HealthzResponse{
+		Status:      HealthzPass,
+		Description: ctx.App().AppName,
+	})
+}
+
+func (r *router) readyz(ctx *azugo.Context) {
+	ctx.SkipRequestLog()
+
+	checks := map[string][]*HealthzCheck{
+		"server:shutdown": {check(ctx, "system", func(_ context.Context) error {
+			if r.ShuttingDown() {
+				return errors.New("service is shutting down")
+			}
+
+			return nil
+		})},
+		"config:loaded": {check(ctx, "system", func(_ context.Context) error {
+			if !r.ConfigLoaded() {
+				return errors.New("configuration is not loaded")
+			}
+
+			return nil
+		})},
+	}
+
+	// dependency check results are shared with /healthz to not overload Vault
+	health := r.healthChecks(ctx)
+	checks["vault:token"] = health["vault:token"]
+	checks["csdd:credentials"] = health["csdd:credentials"]
+
+	// interrupted or failed rotation does not prevent serving requests
+	// as long as CSDD accepts the password, which is checked by csdd:credentials
+	checks["csdd:rotation"] = warning(health["csdd:rotation"])
+
+	status := HealthzPass
+
+	for _, c := range checks {
+		if c[0].Status == HealthzFail {
+			status = HealthzFail
+
+			break
+		}
+
+		if c[0].Status == HealthzWarn {
+			status = HealthzWarn
+		}
+	}
+
+	if status == HealthzFail {
+		ctx.StatusCode(fasthttp.StatusServiceUnavailable)
+	}
+
+	ctx.Header.Set("Cache-Control", "no-cache")
+	ctx.JSON(&HealthzResponse{
+		Status:      status,
+		Description: ctx.App().AppName,
+		Checks:      checks,
+	})
+}
+
+// warning returns copy of the check with failure reported as warning.
+func warning(checks []*HealthzCheck) []*HealthzCheck {
+	c := *checks[0]
+	if c.Status == HealthzFail {
+		c.Status = HealthzWarn
+	}
+
+	return []*HealthzCheck{&c}
+}
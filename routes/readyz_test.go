@@ -0,0 +1,31 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package routes_test
+
+import (
+	"testing"
+
+	"git.zzdats.lv/edim/api-mdl/routes"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestReadyzCredentialsSuspended(t *testing.T) {
+	h := newHarness(t)
+
+	// CSDD accepts none of the passwords in Vault
+	h.csdd.SetPassword(testUser, "Cita-Parole-2025")
+
+	resp := h.get("/1.0/mdl")
+	expectStatus(t, resp, fasthttp.StatusServiceUnavailable)
+
+	resp = h.get("/readyz")
+	expectStatus(t, resp, fasthttp.StatusServiceUnavailable)
+
+	ready := &routes.HealthzResponse{}
+	h.decode(resp, ready)
+
+	if c := ready.Checks["csdd:credentials"]; len(c) == 0 || c[0].Status != routes.HealthzFail {
+		t.Errorf("expected failed csdd:credentials check, got %v", c)
+	}
+}
@@ -0,0 +1,27 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package requests
+
+// JWK is the public EC key in JSON Web Key format (RFC 7517).
+type JWK struct {
+	// Kty is the key type, must be EC
+	Kty string `json:"kty"`
+	// Crv is the curve: P-256, P-384 or P-521
+	Crv string `json:"crv"`
+	// X is the base64url encoded x coordinate
+	X string `json:"x"`
+	// Y is the base64url encoded y coordinate
+	Y string `json:"y"`
+}
+
+// MDocRequest is the request to issue mdoc bound to the holder device key.
+type MDocRequest struct {
+	// DeviceKey is the public key of the holder device
+	DeviceKey *JWK `json:"device_key"`
+}
+
+// SDJWTRequest is the request to issue SD-JWT VC.
+type SDJWTRequest struct {
+	// HolderKey is the public key of the holder added as cnf claim for key binding, optional
+	HolderKey *JWK `json:"holder_key,omitempty"`
+}
@@ -0,0 +1,47 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package responses
+
+import "time"
+
+// RotatePasswordResponse defines the response structure for the forced CSDD password rotation.
+type RotatePasswordResponse struct {
+	// VaultVersion represents Vault secret version of the new CSDD password
+	VaultVersion int `json:"vault_version"`
+}
+
+// CredentialsResponse defines the response structure for the CSDD credential status.
+type CredentialsResponse struct {
+	// VaultVersion represents current Vault secret version of the CSDD password
+	VaultVersion int `json:"vault_version"`
+	// CreatedTime represents time when current password was saved to Vault
+	CreatedTime time.Time `json:"created_time"`
+	// RotationDueTime represents time when password will be rotated because of its age
+	RotationDueTime *time.Time `json:"rotation_due_time,omitempty"`
+	// DaysUntilRotation represents number of days left until password will be rotated because of its age
+	DaysUntilRotation *int `json:"days_until_rotation,omitempty"`
+	// LastPM represents password management flag returned by CSDD on the last login
+	LastPM *int `json:"last_pm,omitempty"`
+	// LastRotation represents the state of the last password rotation
+	LastRotation *RotationStatus `json:"last_rotation,omitempty"`
+	// PendingReconciliation represents if interrupted password rotation is waiting to be reconciled
+	PendingReconciliation bool `json:"pending_reconciliation"`
+	// LoginSuspendedUntil represents time until CSDD login is suspended after failed password recovery
+	LoginSuspendedUntil *time.Time `json:"login_suspended_until,omitempty"`
+}
+
+// RotationStatus defines the state of the CSDD password rotation.
+type RotationStatus struct {
+	// State represents rotation state: pending, confirmed or committed
+	State string `json:"state"`
+	// Result represents rotation outcome: rotated, rolled_back, failed or reconciled
+	Result string `json:"result,omitempty"`
+	// Error represents error message of the failed rotation
+	Error string `json:"error,omitempty"`
+	// FromVersion represents Vault secret version before rotation
+	FromVersion int `json:"from_version"`
+	// ToVersion represents Vault secret version after rotation
+	ToVersion int `json:"to_version"`
+	// UpdatedTime represents time of the last rotation state change
+	UpdatedTime time.Time `json:"updated_time"`
+}
@@ -4,6 +4,8 @@ package responses
 
 import (
 	"git.zzdats.lv/edim/api-mdl/utils"
+
+	"github.com/fxamacker/cbor/v2"
 )
 
 // CategoryRestriction defines the driving privilege category restriction.
@@ -54,6 +56,24 @@ type MDLResponse struct {
 	Portrait string `json:"portrait"`
 }
 
+// MarshalCBOR encodes response in CBOR with portrait as bstr and dates as full-date (tag 1004).
+func (r *MDLResponse) MarshalCBOR() ([]byte, error) {
+	type mdl MDLResponse
+
+	portrait, err := utils.DecodeBase64(r.Portrait)
+	if err != nil {
+		return nil, err
+	}
+
+	return cbor.Marshal(&struct {
+		*mdl
+		Portrait []byte `json:"portrait,omitempty"`
+	}{
+		mdl:      (*mdl)(r),
+		Portrait: portrait,
+	})
+}
+
 type ChangePasswordResponse struct {
 	Errors []ErrorResponse `json:"errors"`
 }
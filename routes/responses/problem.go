@@ -0,0 +1,19 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package responses
+
+// Problem defines the RFC 9457 problem details error response.
+type Problem struct {
+	// Type represents URI identifying the problem type
+	Type string `json:"type"`
+	// Title represents short human-readable summary of the problem type
+	Title string `json:"title"`
+	// Status represents HTTP status code
+	Status int `json:"status"`
+	// Detail represents human-readable explanation specific to this occurrence of the problem
+	Detail string `json:"detail,omitempty"`
+	// Instance represents URI reference of the request that caused the problem
+	Instance string `json:"instance,omitempty"`
+	// CorrelationID represents identifier of the request to be used when reporting the problem
+	CorrelationID string `json:"correlation_id"`
+}
@@ -42,11 +42,11 @@ type VaultGetDataResponse struct {
 			Password string `json:"edim-csdd-service-password"`
 		} `json:"data"`
 		Metadata struct {
-			CreatedTime    time.Time   `json:"created_time"`
-			CustomMetadata interface{} `json:"custom_metadata"`
-			DeletionTime   string      `json:"deletion_time"`
-			Destroyed      bool        `json:"destroyed"`
-			Version        int         `json:"version"`
+			CreatedTime    time.Time         `json:"created_time"`
+			CustomMetadata map[string]string `json:"custom_metadata"`
+			DeletionTime   string            `json:"deletion_time"`
+			Destroyed      bool              `json:"destroyed"`
+			Version        int               `json:"version"`
 		} `json:"metadata"`
 	} `json:"data"`
 	WrapInfo  interface{} `json:"wrap_info"`
@@ -76,7 +76,35 @@ type VaultSaveDataPostResponse struct {
 }
 
 type VaultPostData struct {
+	Options struct {
+		// CAS is the check-and-set version, write is allowed only if it matches the current secret version
+		CAS int `json:"cas"`
+	} `json:"options"`
 	Data struct {
 		Password string `json:"edim-csdd-service-password"`
 	} `json:"data"`
 }
+
+type VaultMetadataResponse struct {
+	RequestID string `json:"request_id"`
+	Data      struct {
+		CreatedTime    time.Time                       `json:"created_time"`
+		UpdatedTime    time.Time                       `json:"updated_time"`
+		CurrentVersion int                             `json:"current_version"`
+		OldestVersion  int                             `json:"oldest_version"`
+		MaxVersions    int                             `json:"max_versions"`
+		CustomMetadata map[string]string               `json:"custom_metadata"`
+		Versions       map[string]VaultMetadataVersion `json:"versions"`
+	} `json:"data"`
+	Errors []string `json:"errors"`
+}
+
+type VaultMetadataVersion struct {
+	CreatedTime  time.Time `json:"created_time"`
+	DeletionTime string    `json:"deletion_time"`
+	Destroyed    bool      `json:"destroyed"`
+}
+
+type VaultMetadataPostData struct {
+	CustomMetadata map[string]string `json:"custom_metadata"`
+}
@@ -5,7 +5,9 @@ package routes
 import (
 	app "git.zzdats.lv/edim/api-mdl"
 	"git.zzdats.lv/edim/api-mdl/openapi"
+	"git.zzdats.lv/edim/api-mdl/tracing"
 
+	"azugo.io/azugo"
 	"github.com/nobid-lsp-latvia/go-idauth"
 	oa "github.com/nobid-lsp-latvia/go-openapi"
 )
@@ -13,22 +15,58 @@ import (
 type router struct {
 	*app.App
 	openapi *oa.OpenAPI
+	health  *healthChecker
 }
 
 func Init(a *app.App) error {
 	r := &router{
-		App: a,
+		App:    a,
+		health: &healthChecker{},
 	}
 	r.openapi = oa.NewDefaultOpenAPIHandler(openapi.OpenAPIDefinition, a.App)
 
 	a.Get("/healthz", r.healthz)
+	a.Get("/livez", r.livez)
+	a.Get("/readyz", r.readyz)
+	a.Get("/metrics", r.metrics)
+
+	if a.ResponseSigner() != nil {
+		a.Get("/.well-known/jwks.json", r.jwks)
+	}
 
 	v1 := a.Group("/1.0")
 	{
-		v1.Use(idauth.Authentication(a.App, a.Config().IDAuth))
+		v1.Use(tracing.Middleware, observeMDL, a.Authentication())
+
+		// responses with personal data are encrypted if client supplies or has registered encryption key
+		// and are never returned in plain text to clients that require encryption
+		v1.Get("/mdl", idauth.UserHasScope("citizen", r.encrypt(r.mdl)))
+
+		if a.MDocIssuer() != nil {
+			v1.Post("/mdl/mdoc", idauth.UserHasScope("citizen", r.encrypt(r.mdoc)))
+		}
+
+		if a.SDJWTIssuer() != nil {
+			v1.Post("/mdl/sd-jwt", idauth.UserHasScope("citizen", r.encrypt(r.sdjwt)))
+		}
+	}
+
+	admin := a.Group("/admin")
+	{
+		admin.Use(tracing.Middleware, a.Authentication())
 
-		v1.Get("/mdl", idauth.UserHasScope("citizen", r.mdl))
+		admin.Post("/csdd/rotate-password", idauth.UserHasScope("admin", r.rotatePassword))
+		admin.Get("/csdd/credentials", idauth.UserHasScope("admin", r.credentials))
 	}
 
 	return nil
 }
+
+// userClaim returns the first value of the authenticated user claim or empty string if claim is not set.
+func userClaim(ctx *azugo.Context, name string) string {
+	if v := ctx.User().Claim(name); len(v) > 0 {
+		return v[0]
+	}
+
+	return ""
+}
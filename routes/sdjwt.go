@@ -0,0 +1,92 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package routes
+
+import (
+	"errors"
+
+	"git.zzdats.lv/edim/api-mdl/routes/requests"
+	"git.zzdats.lv/edim/api-mdl/sdjwt"
+	"git.zzdats.lv/edim/api-mdl/tracing"
+
+	"azugo.io/azugo"
+	"github.com/go-jose/go-jose/v4"
+	"go.uber.org/zap"
+)
+
+// @personId personID
+// @title Issue driving licence SD-JWT VC
+// @description Method returns person driver licence data from CSDD as SD-JWT VC (application/dc+sd-jwt) with
+// @description every claim and every driving privilege selectively disclosable. If holder_key is provided,
+// @description it is added as cnf claim for key binding. Available only when SD-JWT VC issuer key is configured.
+// @param SDJWTRequest body requests.SDJWTRequest false "Holder public key"
+// @success 200 {empty} "SD-JWT VC in compact serialization with disclosures (application/dc+sd-jwt)"
+// @failure 400 Problem responses.Problem "urn:problem-type:api-mdl:invalid-request - holder key is invalid"
+// @failure 401 {empty} "Unauthorized"
+// @failure 403 {empty} "Forbidden"
+// @failure 404 Problem responses.Problem "urn:problem-type:api-mdl:not-found - driving licence data not found"
+// @failure 422 Problem responses.Problem "urn:problem-type:api-mdl:licence-expired - driving licence has expired"
+// @failure 500 Problem responses.Problem "urn:problem-type:api-mdl:internal-error - internal server error"
+// @failure 502 Problem responses.Problem "urn:problem-type:api-mdl:register-error - CSDD returned an error or incomplete driving licence data"
+// @failure 503 Problem responses.Problem "urn:problem-type:api-mdl:register-unavailable - CSDD or Vault is unavailable, Retry-After header is set while CSDD circuit breaker is open"
+// @route /1.0/mdl/sd-jwt [post].
+func (r *router) sdjwt(ctx *azugo.Context) {
+	var err error
+
+	tctx, span := tracing.Start(ctx, "router.sdjwt")
+	defer tracing.End(span, &err)
+
+	id := correlationID(ctx, span.SpanContext())
+	log := ctx.Log().With(zap.String("correlation_id", id))
+
+	// request body is optional as holder key is optional
+	req := &requests.SDJWTRequest{}
+	if len(ctx.Body.Bytes()) > 0 {
+		if err := ctx.Body.JSON(req); err != nil {
+			problem(ctx, id, problemInvalidRequest, "Request body must be JSON object")
+
+			return
+		}
+	}
+
+	var holderKey *jose.JSONWebKey
+
+	if req.HolderKey != nil {
+		var err error
+		if holderKey, err = sdjwt.HolderKey(req.HolderKey); err != nil {
+			problem(ctx, id, problemInvalidRequest, "holder_key must be EC public key in JWK format")
+
+			return
+		}
+	}
+
+	data, err := r.mdlData(ctx, tctx, id, log)
+	if err != nil {
+		return
+	}
+
+	vc, err := r.SDJWTIssuer().Issue(data, holderKey)
+	if err != nil {
+		if errors.Is(err, sdjwt.ErrExpired) {
+			problem(ctx, id, problemLicenceExpired, "SD-JWT VC can not be issued for expired driving licence")
+
+			return
+		}
+
+		if errors.Is(err, sdjwt.ErrIncompleteData) {
+			log.Warn("CSDD returned incomplete driving licence data", zap.Error(err))
+			problem(ctx, id, problemRegisterError, "")
+
+			return
+		}
+
+		log.Error("Failed to issue SD-JWT VC", zap.Error(err))
+		problem(ctx, id, problemInternalError, "")
+
+		return
+	}
+
+	ctx.Header.Set(headerRequestID, id)
+	ctx.ContentType(sdjwt.ContentType)
+	ctx.Raw([]byte(vc))
+}
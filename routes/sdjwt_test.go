@@ -0,0 +1,218 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package routes_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"git.zzdats.lv/edim/api-mdl/routes/requests"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/valyala/fasthttp"
+)
+
+type testSDJWT struct {
+	Iss   string   `json:"iss"`
+	Iat   int64    `json:"iat"`
+	Exp   int64    `json:"exp"`
+	Vct   string   `json:"vct"`
+	SD    []string `json:"_sd"`
+	SDAlg string   `json:"_sd_alg"`
+	Cnf   *struct {
+		JWK *jose.JSONWebKey `json:"jwk"`
+	} `json:"cnf"`
+}
+
+func TestSDJWT(t *testing.T) {
+	h := newHarness(t)
+
+	holder, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	point, err := holder.PublicKey.ECDH()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := point.Bytes()
+
+	resp := h.post("/1.0/mdl/sd-jwt", &requests.SDJWTRequest{
+		HolderKey: &requests.JWK{
+			Kty: "EC",
+			Crv: "P-256",
+			X:   base64.RawURLEncoding.EncodeToString(b[1:33]),
+			Y:   base64.RawURLEncoding.EncodeToString(b[33:]),
+		},
+	})
+	expectStatus(t, resp, fasthttp.StatusOK)
+
+	if ct := string(resp.Header.ContentType()); ct != "application/dc+sd-jwt" {
+		t.Errorf("expected SD-JWT content type, got %q", ct)
+	}
+
+	parts := strings.Split(string(resp.Body()), "~")
+	if len(parts) < 3 || parts[len(parts)-1] != "" {
+		t.Fatalf("expected SD-JWT with disclosures, got %q", resp.Body())
+	}
+
+	jws, err := jose.ParseSigned(parts[0], []jose.SignatureAlgorithm{jose.ES256})
+	if err != nil {
+		t.Fatalf("failed to parse SD-JWT: %v", err)
+	}
+
+	if typ, _ := jws.Signatures[0].Header.ExtraHeaders["typ"].(string); typ != "dc+sd-jwt" {
+		t.Errorf("expected typ dc+sd-jwt, got %q", typ)
+	}
+
+	payload, err := jws.Verify(h.issuer.PublicKey)
+	if err != nil {
+		t.Fatalf("invalid issuer signature: %v", err)
+	}
+
+	claims := &testSDJWT{}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		t.Fatal(err)
+	}
+
+	if claims.Iss != testIssuer || claims.Vct == "" || claims.SDAlg != "sha-256" {
+		t.Errorf("unexpected iss %q, vct %q or _sd_alg %q", claims.Iss, claims.Vct, claims.SDAlg)
+	}
+
+	if exp := time.Unix(claims.Exp, 0).UTC(); exp.Format(time.DateOnly) != "2030-06-01" {
+		t.Errorf("expected exp on driving licence expiry date, got %s", exp)
+	}
+
+	if claims.Cnf == nil || claims.Cnf.JWK == nil || !holder.PublicKey.Equal(claims.Cnf.JWK.Key) {
+		t.Error("expected holder key in cnf claim")
+	}
+
+	// every disclosure must be referenced by digest in payload or in disclosed driving privileges
+	digests := slices.Clone(claims.SD)
+	disclosed := make(map[string]json.RawMessage)
+	privileges := 0
+
+	for _, d := range parts[1 : len(parts)-1] {
+		sum := sha256.Sum256([]byte(d))
+		digest := base64.RawURLEncoding.EncodeToString(sum[:])
+
+		n := slices.Index(digests, digest)
+		if n < 0 {
+			t.Fatalf("disclosure %q is not referenced", d)
+		}
+
+		digests = slices.Delete(digests, n, n+1)
+
+		raw, err := base64.RawURLEncoding.DecodeString(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var values []json.RawMessage
+		if err := json.Unmarshal(raw, &values); err != nil {
+			t.Fatal(err)
+		}
+
+		switch len(values) {
+		case 2:
+			privileges++
+		case 3:
+			var name string
+			_ = json.Unmarshal(values[1], &name)
+			disclosed[name] = values[2]
+
+			if name == "driving_privileges" {
+				var refs []map[string]string
+				_ = json.Unmarshal(values[2], &refs)
+
+				for _, ref := range refs {
+					digests = append(digests, ref["..."])
+				}
+			}
+		default:
+			t.Fatalf("invalid disclosure %s", raw)
+		}
+	}
+
+	if len(digests) != 0 {
+		t.Errorf("expected all digests to have disclosures, %d left", len(digests))
+	}
+
+	if privileges != 2 {
+		t.Errorf("expected 2 disclosable driving privileges, got %d", privileges)
+	}
+
+	if string(disclosed["family_name"]) != `"BĒRZIŅŠ"` || string(disclosed["personal_administrative_number"]) != `"`+testPerson+`"` {
+		t.Errorf("unexpected disclosed family name %s or personal administrative number %s",
+			disclosed["family_name"], disclosed["personal_administrative_number"])
+	}
+}
+
+func TestSDJWTWithoutHolderKey(t *testing.T) {
+	h := newHarness(t)
+
+	resp := h.post("/1.0/mdl/sd-jwt", &requests.SDJWTRequest{})
+	expectStatus(t, resp, fasthttp.StatusOK)
+
+	jws, err := jose.ParseSigned(strings.Split(string(resp.Body()), "~")[0], []jose.SignatureAlgorithm{jose.ES256})
+	if err != nil {
+		t.Fatalf("failed to parse SD-JWT: %v", err)
+	}
+
+	claims := &testSDJWT{}
+	if err := json.Unmarshal(jws.UnsafePayloadWithoutVerification(), claims); err != nil {
+		t.Fatal(err)
+	}
+
+	if claims.Cnf != nil {
+		t.Error("expected no cnf claim without holder key")
+	}
+}
+
+func TestSDJWTInvalidHolderKey(t *testing.T) {
+	h := newHarness(t)
+
+	resp := h.post("/1.0/mdl/sd-jwt", &requests.SDJWTRequest{
+		HolderKey: &requests.JWK{Kty: "EC", Crv: "P-256", X: "AAAA", Y: "AAAA"},
+	})
+	expectStatus(t, resp, fasthttp.StatusBadRequest)
+
+	h.problem(resp, "urn:problem-type:api-mdl:invalid-request")
+}
+
+func TestSDJWTEmptyBody(t *testing.T) {
+	h := newHarness(t)
+
+	client := h.test.TestClient()
+
+	resp, err := client.Post("/1.0/mdl/sd-jwt", nil)
+	if err != nil {
+		t.Fatalf("POST /1.0/mdl/sd-jwt failed: %v", err)
+	}
+
+	expectStatus(t, resp, fasthttp.StatusOK)
+
+	if ct := string(resp.Header.ContentType()); ct != "application/dc+sd-jwt" {
+		t.Errorf("expected SD-JWT content type, got %q", ct)
+	}
+}
+
+func TestSDJWTExpiredLicence(t *testing.T) {
+	h := newHarness(t)
+	h.person = "32000000003"
+
+	resp := h.post("/1.0/mdl/sd-jwt", &requests.SDJWTRequest{})
+	expectStatus(t, resp, fasthttp.StatusUnprocessableEntity)
+
+	h.problem(resp, "urn:problem-type:api-mdl:licence-expired")
+}
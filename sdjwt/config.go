@@ -0,0 +1,49 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package sdjwt
+
+import (
+	"azugo.io/core/validation"
+	"github.com/spf13/viper"
+)
+
+// Configuration represents the configuration for the SD-JWT VC issuance.
+type Configuration struct {
+	// KeyFile is the PEM file with P-256 issuer private key. Issuance is disabled if not set.
+	KeyFile string `mapstructure:"key_file" validate:"omitempty,file"`
+	// KeyID is the key ID set in JWT header. If not set, JWK thumbprint of the key is used.
+	KeyID string `mapstructure:"key_id"`
+	// Issuer is the issuer identifier set as iss claim.
+	Issuer string `mapstructure:"issuer" validate:"required_with=KeyFile,omitempty,url"`
+	// VCT is the verifiable credential type set as vct claim.
+	VCT string `mapstructure:"vct" validate:"required"`
+}
+
+func (c *Configuration) Bind(prefix string, v *viper.Viper) {
+	v.SetDefault(prefix+".vct", "urn:eudi:mdl:1")
+
+	_ = v.BindEnv(prefix+".key_file", "SDJWT_KEY_FILE")
+	_ = v.BindEnv(prefix+".key_id", "SDJWT_KEY_ID")
+	_ = v.BindEnv(prefix+".issuer", "SDJWT_ISSUER")
+	_ = v.BindEnv(prefix+".vct", "SDJWT_VCT")
+}
+
+// Enabled returns true if SD-JWT VC issuer key is configured.
+func (c *Configuration) Enabled() bool {
+	return c.KeyFile != ""
+}
+
+// Validate SD-JWT VC configuration section.
+func (c *Configuration) Validate(valid *validation.Validate) error {
+	if err := valid.Struct(c); err != nil {
+		return err
+	}
+
+	if !c.Enabled() {
+		return nil
+	}
+
+	_, err := loadKey(c.KeyFile)
+
+	return err
+}
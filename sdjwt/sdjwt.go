@@ -0,0 +1,248 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+// Package sdjwt issues driving licence as SD-JWT VC.
+package sdjwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"git.zzdats.lv/edim/api-mdl/routes/requests"
+	"git.zzdats.lv/edim/api-mdl/routes/responses"
+	"git.zzdats.lv/edim/api-mdl/utils"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+const (
+	// ContentType is the content type of the issued SD-JWT VC.
+	ContentType = "application/dc+sd-jwt"
+
+	// typ is the JWT type header value of SD-JWT VC.
+	typ = "dc+sd-jwt"
+
+	// saltSize is the size of random salt of the disclosure, at least 128 bits are recommended.
+	saltSize = 16
+
+	// drivingPrivilegesClaim is the claim which array elements are also selectively disclosable.
+	drivingPrivilegesClaim = "driving_privileges"
+)
+
+var (
+	// ErrInvalidHolderKey is returned when holder key is not a valid EC public key.
+	ErrInvalidHolderKey = errors.New("invalid holder key")
+	// ErrIncompleteData is returned when driving licence data lacks data required for SD-JWT VC.
+	ErrIncompleteData = errors.New("driving licence data is incomplete")
+	// ErrExpired is returned when driving licence has expired, so SD-JWT VC would never be valid.
+	ErrExpired = errors.New("driving licence has expired")
+)
+
+// Issuer issues SD-JWT VC signed with the issuer key.
+type Issuer struct {
+	config *Configuration
+	signer jose.Signer
+}
+
+// loadKey loads P-256 issuer key used with ES256 algorithm.
+func loadKey(keyFile string) (*ecdsa.PrivateKey, error) {
+	key, err := utils.LoadECKey(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("SD-JWT issuer key: %w", err)
+	}
+
+	if key.Curve != elliptic.P256() {
+		return nil, errors.New("SD-JWT issuer key must be P-256 key")
+	}
+
+	return key, nil
+}
+
+// New returns SD-JWT VC issuer with the configured issuer key.
+func New(config *Configuration) (*Issuer, error) {
+	key, err := loadKey(config.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	kid := config.KeyID
+	if kid == "" {
+		thumbprint, err := (&jose.JSONWebKey{Key: &key.PublicKey}).Thumbprint(crypto.SHA256)
+		if err != nil {
+			return nil, err
+		}
+
+		kid = base64.RawURLEncoding.EncodeToString(thumbprint)
+	}
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.ES256, Key: key},
+		(&jose.SignerOptions{}).WithType(typ).WithHeader("kid", kid),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Issuer{
+		config: config,
+		signer: signer,
+	}, nil
+}
+
+// HolderKey returns holder public key from JWK.
+func HolderKey(jwk *requests.JWK) (*jose.JSONWebKey, error) {
+	b, err := json.Marshal(jwk)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &jose.JSONWebKey{}
+	if err := key.UnmarshalJSON(b); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidHolderKey, err)
+	}
+
+	if _, ok := key.Key.(*ecdsa.PublicKey); !ok || !key.Valid() {
+		return nil, ErrInvalidHolderKey
+	}
+
+	return key, nil
+}
+
+// Issue returns SD-JWT VC of the driving licence with all claims selectively disclosable.
+//
+// Each driving privilege is also disclosable separately. If holder key is set, it is added as cnf claim.
+func (i *Issuer) Issue(data *responses.MDLResponse, holderKey *jose.JSONWebKey) (string, error) {
+	if time.Time(data.ExpiryDate).IsZero() {
+		return "", fmt.Errorf("%w: expiry_date is missing", ErrIncompleteData)
+	}
+
+	now := time.Now()
+
+	exp := expiry(data.ExpiryDate)
+	if !exp.After(now) {
+		return "", fmt.Errorf("%w on %s", ErrExpired, time.Time(data.ExpiryDate).Format(time.DateOnly))
+	}
+
+	b, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+
+	claims := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(b, &claims); err != nil {
+		return "", err
+	}
+
+	disclosures := make([]string, 0, len(claims))
+	digests := make([]string, 0, len(claims))
+
+	for name, value := range claims {
+		switch string(value) {
+		case "null", `""`, "[]":
+			continue
+		}
+
+		if name == drivingPrivilegesClaim {
+			if value, err = discloseElements(value, &disclosures); err != nil {
+				return "", err
+			}
+		}
+
+		d, digest, err := disclose(name, value)
+		if err != nil {
+			return "", err
+		}
+
+		disclosures = append(disclosures, d)
+		digests = append(digests, digest)
+	}
+
+	// digests are sorted to not reveal the original order of claims
+	sort.Strings(digests)
+
+	payload := map[string]any{
+		"iss":     i.config.Issuer,
+		"iat":     now.Unix(),
+		"exp":     exp.Unix(),
+		"vct":     i.config.VCT,
+		"_sd":     digests,
+		"_sd_alg": "sha-256",
+	}
+
+	if holderKey != nil {
+		payload["cnf"] = map[string]any{"jwk": holderKey}
+	}
+
+	p, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	jws, err := i.signer.Sign(p)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign SD-JWT: %w", err)
+	}
+
+	jwt, err := jws.CompactSerialize()
+	if err != nil {
+		return "", err
+	}
+
+	return jwt + "~" + strings.Join(disclosures, "~") + "~", nil
+}
+
+// discloseElements returns array with each element replaced by digest of its disclosure.
+func discloseElements(value json.RawMessage, disclosures *[]string) (json.RawMessage, error) {
+	var elements []json.RawMessage
+	if err := json.Unmarshal(value, &elements); err != nil {
+		return nil, err
+	}
+
+	result := make([]map[string]string, 0, len(elements))
+
+	for _, e := range elements {
+		d, digest, err := disclose(e)
+		if err != nil {
+			return nil, err
+		}
+
+		*disclosures = append(*disclosures, d)
+		result = append(result, map[string]string{"...": digest})
+	}
+
+	return json.Marshal(result)
+}
+
+// disclose returns disclosure of the claim name and value or of the array element value and its digest.
+func disclose(values ...any) (string, string, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", "", err
+	}
+
+	b, err := json.Marshal(append([]any{base64.RawURLEncoding.EncodeToString(salt)}, values...))
+	if err != nil {
+		return "", "", err
+	}
+
+	d := base64.RawURLEncoding.EncodeToString(b)
+	digest := sha256.Sum256([]byte(d))
+
+	return d, base64.RawURLEncoding.EncodeToString(digest[:]), nil
+}
+
+// expiry returns the end of driving licence expiry date.
+func expiry(d utils.Date) time.Time {
+	y, m, day := time.Time(d).Date()
+
+	return time.Date(y, m, day, 23, 59, 59, 0, time.UTC)
+}
@@ -0,0 +1,53 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package signing
+
+import (
+	"azugo.io/core/validation"
+	"github.com/spf13/viper"
+)
+
+// Configuration represents the configuration for the response signing.
+type Configuration struct {
+	// KeyFile is the PEM or JWK file with EC private key used to sign responses.
+	// Signed responses are disabled if not set.
+	KeyFile string `mapstructure:"key_file" validate:"omitempty,file"`
+	// KeyID is the key ID set in JWS header. If not set, kid of JWK or JWK thumbprint of the key is used.
+	KeyID string `mapstructure:"key_id"`
+	// PreviousKeyFiles are PEM or JWK files with keys that are no longer used to sign responses,
+	// but are still published in JWKS so that earlier responses can be verified.
+	PreviousKeyFiles []string `mapstructure:"previous_key_files" validate:"dive,file"`
+	// PreviousKeyIDs are key IDs of the previous keys in the same order as PreviousKeyFiles.
+	// Empty key ID means kid of JWK or JWK thumbprint of the key is used.
+	PreviousKeyIDs []string `mapstructure:"previous_key_ids"`
+	// Detached enables detached JWS of JSON responses in X-JWS-Signature header.
+	Detached bool `mapstructure:"detached"`
+}
+
+func (c *Configuration) Bind(prefix string, v *viper.Viper) {
+	_ = v.BindEnv(prefix+".key_file", "RESPONSE_SIGNING_KEY_FILE")
+	_ = v.BindEnv(prefix+".key_id", "RESPONSE_SIGNING_KEY_ID")
+	_ = v.BindEnv(prefix+".previous_key_files", "RESPONSE_SIGNING_PREVIOUS_KEY_FILES")
+	_ = v.BindEnv(prefix+".previous_key_ids", "RESPONSE_SIGNING_PREVIOUS_KEY_IDS")
+	_ = v.BindEnv(prefix+".detached", "RESPONSE_SIGNING_DETACHED")
+}
+
+// Enabled returns true if response signing key is configured.
+func (c *Configuration) Enabled() bool {
+	return c.KeyFile != ""
+}
+
+// Validate response signing configuration section.
+func (c *Configuration) Validate(valid *validation.Validate) error {
+	if err := valid.Struct(c); err != nil {
+		return err
+	}
+
+	if !c.Enabled() {
+		return nil
+	}
+
+	_, err := New(c)
+
+	return err
+}
@@ -0,0 +1,85 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package signing
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"git.zzdats.lv/edim/api-mdl/utils"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+// loadKey loads EC key from JWK file or PEM file with private or public key.
+//
+// Key ID is taken from JWK or set to JWK thumbprint of the key.
+func loadKey(file string) (*jose.JSONWebKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	key := &jose.JSONWebKey{}
+
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		if err := key.UnmarshalJSON(data); err != nil {
+			return nil, fmt.Errorf("invalid JWK in %s: %w", file, err)
+		}
+	} else if block, _ := pem.Decode(data); block != nil && block.Type == "PUBLIC KEY" {
+		if key.Key, err = x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+			return nil, fmt.Errorf("invalid public key in %s: %w", file, err)
+		}
+	} else if key.Key, err = utils.LoadECKey(file); err != nil {
+		return nil, fmt.Errorf("invalid key in %s: %w", file, err)
+	}
+
+	var curve elliptic.Curve
+
+	switch k := key.Key.(type) {
+	case *ecdsa.PrivateKey:
+		curve = k.Curve
+	case *ecdsa.PublicKey:
+		curve = k.Curve
+	default:
+		return nil, fmt.Errorf("key in %s must be EC key", file)
+	}
+
+	switch curve {
+	case elliptic.P256():
+		key.Algorithm = string(jose.ES256)
+	case elliptic.P384():
+		key.Algorithm = string(jose.ES384)
+	case elliptic.P521():
+		key.Algorithm = string(jose.ES512)
+	default:
+		return nil, fmt.Errorf("unsupported curve %s of key in %s", curve.Params().Name, file)
+	}
+
+	key.Use = "sig"
+
+	if key.KeyID == "" {
+		public := key.Public()
+
+		thumbprint, err := public.Thumbprint(crypto.SHA256)
+		if err != nil {
+			return nil, err
+		}
+
+		key.KeyID = base64.RawURLEncoding.EncodeToString(thumbprint)
+	}
+
+	if !key.Valid() {
+		return nil, errors.New("invalid key in " + file)
+	}
+
+	return key, nil
+}
@@ -0,0 +1,151 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+// Package signing signs API responses as JWS.
+package signing
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+const (
+	// Source is the source of the signed data set in source claim.
+	Source = "CSDD"
+
+	// HeaderSignature is the response header with detached JWS of the response body.
+	HeaderSignature = "X-JWS-Signature"
+)
+
+// Signer signs responses with the current key and publishes current and previous keys.
+type Signer struct {
+	key  *jose.JSONWebKey
+	jwks *jose.JSONWebKeySet
+}
+
+// New returns response signer with the configured keys.
+func New(config *Configuration) (*Signer, error) {
+	key, err := loadKey(config.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("response signing key: %w", err)
+	}
+
+	if key.IsPublic() {
+		return nil, errors.New("response signing key must be private key")
+	}
+
+	if config.KeyID != "" {
+		key.KeyID = config.KeyID
+	}
+
+	jwks := &jose.JSONWebKeySet{
+		Keys: []jose.JSONWebKey{key.Public()},
+	}
+
+	// key ID of previous key must stay the same as it was when the key was used to sign responses
+	if len(config.PreviousKeyIDs) > 0 && len(config.PreviousKeyIDs) != len(config.PreviousKeyFiles) {
+		return nil, fmt.Errorf("expected %d previous response signing key IDs, got %d",
+			len(config.PreviousKeyFiles), len(config.PreviousKeyIDs))
+	}
+
+	for i, file := range config.PreviousKeyFiles {
+		prev, err := loadKey(file)
+		if err != nil {
+			return nil, fmt.Errorf("previous response signing key: %w", err)
+		}
+
+		if len(config.PreviousKeyIDs) > 0 && config.PreviousKeyIDs[i] != "" {
+			prev.KeyID = config.PreviousKeyIDs[i]
+		}
+
+		if len(jwks.Key(prev.KeyID)) > 0 {
+			return nil, fmt.Errorf("duplicate response signing key ID %q", prev.KeyID)
+		}
+
+		jwks.Keys = append(jwks.Keys, prev.Public())
+	}
+
+	return &Signer{
+		key:  key,
+		jwks: jwks,
+	}, nil
+}
+
+// signer returns JWS signer with kid and additional protected headers.
+func (s *Signer) signer(opts *jose.SignerOptions) (jose.Signer, error) {
+	return jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.SignatureAlgorithm(s.key.Algorithm), Key: s.key.Key},
+		opts.WithHeader("kid", s.key.KeyID),
+	)
+}
+
+// Sign returns JWT in JWS compact serialization with claims of v and iat and source claims.
+func (s *Signer) Sign(v any) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	claims := make(map[string]any)
+	if err := json.Unmarshal(b, &claims); err != nil {
+		return "", err
+	}
+
+	claims["iat"] = time.Now().Unix()
+	claims["source"] = Source
+
+	if b, err = json.Marshal(claims); err != nil {
+		return "", err
+	}
+
+	signer, err := s.signer((&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		return "", err
+	}
+
+	jws, err := signer.Sign(b)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign response: %w", err)
+	}
+
+	return jws.CompactSerialize()
+}
+
+// SignDetached returns JWS compact serialization with detached payload (RFC 7515 Appendix F).
+//
+// As payload can not be changed, iat and source are set as protected headers.
+func (s *Signer) SignDetached(payload []byte) (string, error) {
+	signer, err := s.signer((&jose.SignerOptions{}).
+		WithHeader("iat", time.Now().Unix()).
+		WithHeader("source", Source))
+	if err != nil {
+		return "", err
+	}
+
+	jws, err := signer.Sign(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign response: %w", err)
+	}
+
+	return jws.DetachedCompactSerialize()
+}
+
+// Attach returns compact JWS of the detached signature with the payload attached.
+func Attach(signature string, payload []byte) (string, error) {
+	header, sig, ok := strings.Cut(signature, "..")
+	if !ok || header == "" || sig == "" {
+		return "", errors.New("invalid detached JWS")
+	}
+
+	return header + "." + base64.RawURLEncoding.EncodeToString(payload) + "." + sig, nil
+}
+
+// JWKS returns public keys to verify signed responses.
+func (s *Signer) JWKS() *jose.JSONWebKeySet {
+	return s.jwks
+}
@@ -0,0 +1,44 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package tracing
+
+import (
+	"azugo.io/core/validation"
+	"github.com/spf13/viper"
+)
+
+// Exporter types.
+const (
+	// ExporterNone does not export spans, but trace context is still propagated.
+	ExporterNone = "none"
+	// ExporterStdout writes spans to standard output for local testing.
+	ExporterStdout = "stdout"
+	// ExporterOTLP sends spans to OpenTelemetry collector using OTLP over HTTP.
+	ExporterOTLP = "otlp"
+)
+
+// Configuration represents the configuration for the tracing.
+type Configuration struct {
+	Exporter string `mapstructure:"exporter" validate:"oneof=none stdout otlp"`
+	// Endpoint is the OTLP HTTP endpoint URL (e.g. http://otel-collector:4318/v1/traces).
+	Endpoint string `mapstructure:"endpoint" validate:"required_if=Exporter otlp,omitempty,url"`
+	// Headers are additional comma separated key=value headers sent to OTLP endpoint (e.g. for authentication).
+	Headers string `mapstructure:"headers"`
+	// SampleRatio is the ratio of new traces to be sampled. Sampling decision of the caller is always respected.
+	SampleRatio float64 `mapstructure:"sample_ratio" validate:"gte=0,lte=1"`
+}
+
+func (c *Configuration) Bind(prefix string, v *viper.Viper) {
+	v.SetDefault(prefix+".exporter", ExporterNone)
+	v.SetDefault(prefix+".sample_ratio", 1.0)
+
+	_ = v.BindEnv(prefix+".exporter", "TRACING_EXPORTER")
+	_ = v.BindEnv(prefix+".endpoint", "TRACING_OTLP_ENDPOINT")
+	_ = v.BindEnv(prefix+".headers", "TRACING_OTLP_HEADERS")
+	_ = v.BindEnv(prefix+".sample_ratio", "TRACING_SAMPLE_RATIO")
+}
+
+// Validate tracing configuration section.
+func (c *Configuration) Validate(valid *validation.Validate) error {
+	return valid.Struct(c)
+}
@@ -0,0 +1,224 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+// Package tracing contains OpenTelemetry tracing setup and helpers to trace requests to upstream services.
+package tracing
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"azugo.io/azugo"
+	"azugo.io/core"
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "git.zzdats.lv/edim/api-mdl"
+
+// spanUserValue is the request user value key that holds context with the server span.
+const spanUserValue = "tracing_span_context"
+
+var (
+	tracer     = otel.Tracer(instrumentationName)
+	propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+)
+
+// requestKey is the context key of the request context spans are started from.
+type requestKey struct{}
+
+type provider struct {
+	tp *sdktrace.TracerProvider
+}
+
+// New configures global tracer provider with the configured exporter.
+//
+// W3C trace context is propagated even if spans are not exported.
+func New(app *core.App, config *Configuration) error {
+	otel.SetTextMapPropagator(propagator)
+
+	var (
+		exporter sdktrace.SpanExporter
+		err      error
+	)
+
+	switch config.Exporter {
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case ExporterOTLP:
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpointURL(config.Endpoint)}
+		if headers := parseHeaders(config.Headers); len(headers) > 0 {
+			opts = append(opts, otlptracehttp.WithHeaders(headers))
+		}
+
+		exporter, err = otlptracehttp.New(context.Background(), opts...)
+	default:
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", app.AppName),
+		attribute.String("service.version", app.AppVer),
+	))
+	if err != nil {
+		return err
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+	)
+
+	otel.SetTracerProvider(tp)
+
+	app.AddTask(&provider{tp: tp})
+
+	return nil
+}
+
+// Name returns the name of the tracing background task.
+func (p *provider) Name() string {
+	return "tracing"
+}
+
+func (p *provider) Start(_ context.Context) error {
+	return nil
+}
+
+// Stop flushes spans that are not yet exported.
+func (p *provider) Stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_ = p.tp.Shutdown(ctx)
+}
+
+// parseHeaders parses comma separated key=value pairs.
+func parseHeaders(s string) map[string]string {
+	headers := make(map[string]string)
+
+	for _, pair := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			continue
+		}
+
+		headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+
+	return headers
+}
+
+// headerCarrier reads W3C trace context from request headers.
+type headerCarrier struct {
+	ctx *azugo.Context
+}
+
+func (c headerCarrier) Get(key string) string {
+	return c.ctx.Header.Get(key)
+}
+
+func (c headerCarrier) Set(_, _ string) {}
+
+func (c headerCarrier) Keys() []string {
+	return nil
+}
+
+// Middleware starts server span for the request continuing trace from the request headers.
+func Middleware(next azugo.RequestHandler) azugo.RequestHandler {
+	return func(ctx *azugo.Context) {
+		parent := propagator.Extract(context.WithoutCancel(ctx), headerCarrier{ctx: ctx})
+
+		sctx, span := tracer.Start(parent, ctx.Method()+" "+ctx.Path(),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", ctx.Method()),
+				attribute.String("url.path", ctx.Path()),
+			))
+		defer span.End()
+
+		ctx.SetUserValue(spanUserValue, sctx)
+
+		next(ctx)
+
+		status := ctx.Context().Response.StatusCode()
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+
+		if status >= 500 {
+			span.SetStatus(codes.Error, "")
+		}
+	}
+}
+
+// Start starts a new span as a child of the span in ctx.
+//
+// If ctx is request context, span is started as a child of the server span and
+// request context can be later retrieved from the returned context with RequestContext.
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if c, ok := ctx.(*azugo.Context); ok {
+		parent := context.WithValue(c, requestKey{}, c)
+
+		if sctx, ok := c.UserValue(spanUserValue).(context.Context); ok {
+			parent = trace.ContextWithSpan(parent, trace.SpanFromContext(sctx))
+		}
+
+		ctx = parent
+	}
+
+	return tracer.Start(ctx, name, opts...)
+}
+
+// WithParent makes spans started later from request ctx children of the span in sctx, e.g. spans of the
+// handler wrapped by middleware. Returns function that restores the previous parent.
+func WithParent(ctx *azugo.Context, sctx context.Context) func() {
+	prev := ctx.UserValue(spanUserValue)
+	ctx.SetUserValue(spanUserValue, sctx)
+
+	return func() {
+		ctx.SetUserValue(spanUserValue, prev)
+	}
+}
+
+// RequestContext returns request context that ctx has been derived from.
+func RequestContext(ctx context.Context) (*azugo.Context, bool) {
+	if c, ok := ctx.(*azugo.Context); ok {
+		return c, true
+	}
+
+	c, ok := ctx.Value(requestKey{}).(*azugo.Context)
+
+	return c, ok
+}
+
+// InjectHeader propagates trace context to outbound request.
+func InjectHeader(ctx context.Context, header *fasthttp.RequestHeader) {
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+
+	for key, value := range carrier {
+		header.Set(key, value)
+	}
+}
+
+// End records error if any and ends the span.
+func End(span trace.Span, err *error) {
+	if err != nil && *err != nil {
+		span.RecordError(*err)
+		span.SetStatus(codes.Error, (*err).Error())
+	}
+
+	span.End()
+}
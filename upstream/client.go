@@ -0,0 +1,182 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+// Package upstream implements HTTP client for CSDD and Vault with per-upstream timeouts, TLS and proxy settings.
+package upstream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+
+	"git.zzdats.lv/edim/api-mdl/tracing"
+
+	"azugo.io/core/http"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttpproxy"
+)
+
+// RequestOption modifies outbound request.
+type RequestOption func(req *fasthttp.Request)
+
+// WithHeader sets request header.
+func WithHeader(key, value string) RequestOption {
+	return func(req *fasthttp.Request) {
+		req.Header.Set(key, value)
+	}
+}
+
+// StatusError is returned when upstream responds with HTTP error status.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return "unexpected HTTP status " + strconv.Itoa(e.StatusCode) + " " + fasthttp.StatusMessage(e.StatusCode)
+}
+
+// Is allows to check for not found response with http.NotFoundError.
+func (e *StatusError) Is(target error) bool {
+	_, ok := target.(http.NotFoundError)
+
+	return ok && e.StatusCode == fasthttp.StatusNotFound
+}
+
+// Client is HTTP client of the upstream service.
+type Client struct {
+	client *fasthttp.Client
+	dial   fasthttp.DialFunc
+}
+
+// New returns HTTP client configured for the upstream service.
+func New(name string, config *Configuration) (*Client, error) {
+	tlsConfig, err := config.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var dial fasthttp.DialFunc
+
+	if config.ProxyURL != "" {
+		dial = fasthttpproxy.FasthttpHTTPDialerTimeout(config.ProxyURL, config.ConnectTimeout)
+	} else {
+		dialer := &fasthttp.TCPDialer{}
+		dial = func(addr string) (net.Conn, error) {
+			if config.ConnectTimeout > 0 {
+				return dialer.DialTimeout(addr, config.ConnectTimeout)
+			}
+
+			return dialer.Dial(addr)
+		}
+	}
+
+	return &Client{
+		client: &fasthttp.Client{
+			Name:         name,
+			Dial:         dial,
+			TLSConfig:    tlsConfig,
+			ReadTimeout:  config.ReadTimeout,
+			WriteTimeout: config.WriteTimeout,
+		},
+		dial: dial,
+	}, nil
+}
+
+// GetJSON sends GET request and decodes JSON response into v.
+func (c *Client) GetJSON(ctx context.Context, url string, v any, opts ...RequestOption) error {
+	return c.do(ctx, fasthttp.MethodGet, url, nil, v, opts)
+}
+
+// PostJSON sends body encoded as JSON and decodes JSON response into v.
+func (c *Client) PostJSON(ctx context.Context, url string, body, v any, opts ...RequestOption) error {
+	return c.do(ctx, fasthttp.MethodPost, url, body, v, opts)
+}
+
+// Ping checks if connection to the host of the URL can be established through the configured proxy.
+func (c *Client) Ping(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	conn, err := c.dial(net.JoinHostPort(u.Hostname(), port))
+	if err != nil {
+		return err
+	}
+
+	return conn.Close()
+}
+
+// do sends request propagating trace context.
+//
+// Response body is decoded into v also for error responses, as upstream can return error details in it.
+func (c *Client) do(ctx context.Context, method, url string, body, v any, opts []RequestOption) error {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.SetRequestURI(url)
+	req.Header.SetMethod(method)
+	req.Header.Set(fasthttp.HeaderAccept, "application/json")
+
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+
+		req.Header.SetContentType("application/json")
+		req.SetBodyRaw(b)
+	}
+
+	tracing.InjectHeader(ctx, &req.Header)
+
+	for _, opt := range opts {
+		opt(req)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var err error
+	if deadline, ok := ctx.Deadline(); ok {
+		err = c.client.DoDeadline(req, resp, deadline)
+	} else {
+		err = c.client.Do(req, resp)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	status := resp.StatusCode()
+
+	if v != nil && len(resp.Body()) > 0 {
+		if err := json.Unmarshal(resp.Body(), v); err != nil && status < fasthttp.StatusMultipleChoices {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+
+	if status >= fasthttp.StatusMultipleChoices {
+		return &StatusError{StatusCode: status}
+	}
+
+	return nil
+}
@@ -0,0 +1,19 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package utils
+
+import (
+	"encoding/base64"
+	"strings"
+)
+
+// DecodeBase64 decodes base64 data in standard or URL-safe alphabet with or without padding.
+func DecodeBase64(s string) ([]byte, error) {
+	s = strings.TrimRight(s, "=")
+
+	if strings.ContainsAny(s, "-_") {
+		return base64.RawURLEncoding.DecodeString(s)
+	}
+
+	return base64.RawStdEncoding.DecodeString(s)
+}
@@ -0,0 +1,41 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// LoadECKey loads EC private key in SEC 1 or PKCS #8 PEM format.
+func LoadECKey(keyFile string) (*ecdsa.PrivateKey, error) {
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("key file does not contain PEM private key")
+	}
+
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		if key, ok := k.(*ecdsa.PrivateKey); ok {
+			return key, nil
+		}
+	}
+
+	return nil, errors.New("key must be EC private key")
+}
@@ -5,8 +5,13 @@ package utils
 import (
 	"fmt"
 	"time"
+
+	"github.com/fxamacker/cbor/v2"
 )
 
+// tagFullDate is the CBOR tag of RFC 3339 full-date string (RFC 8943).
+const tagFullDate = 1004
+
 // Time is a wrapper around time.Time that supports JSON marshaling.
 type Time time.Time
 
@@ -80,3 +85,12 @@ func (t *Date) UnmarshalJSON(data []byte) error {
 
 	return nil
 }
+
+// MarshalCBOR encodes date as full-date (tag 1004).
+func (t Date) MarshalCBOR() ([]byte, error) {
+	if time.Time(t).IsZero() {
+		return cbor.Marshal(nil)
+	}
+
+	return cbor.Marshal(cbor.Tag{Number: tagFullDate, Content: t.String()})
+}
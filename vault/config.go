@@ -3,6 +3,8 @@
 package vault
 
 import (
+	"git.zzdats.lv/edim/api-mdl/upstream"
+
 	"azugo.io/core/config"
 	"azugo.io/core/validation"
 	"github.com/spf13/viper"
@@ -14,6 +16,10 @@ type Configuration struct {
 	DataURL  string `mapstructure:"url_data"`
 	RoleID   string `mapstructure:"role_id"`
 	SecretID string `mapstructure:"secret_id"`
+	// MetadataURL is the KV v2 metadata URL of the secret. If not set, it is derived from DataURL.
+	MetadataURL string `mapstructure:"url_metadata"`
+	// Client is the HTTP client configuration for Vault.
+	Client *upstream.Configuration `mapstructure:"client"`
 }
 
 func (c *Configuration) Bind(prefix string, v *viper.Viper) {
@@ -22,11 +28,18 @@ func (c *Configuration) Bind(prefix string, v *viper.Viper) {
 
 	_ = v.BindEnv(prefix+".url_login", "VAULT_LOGIN_URL")
 	_ = v.BindEnv(prefix+".url_data", "VAULT_DATA_URL")
+	_ = v.BindEnv(prefix+".url_metadata", "VAULT_METADATA_URL")
 	_ = v.BindEnv(prefix+".role_id", "VAULT_ROLE_ID")
 	_ = v.BindEnv(prefix+".secret_id", "VAULT_SECRET_ID")
+
+	c.Client = upstream.Bind(c.Client, prefix+".client", "VAULT", v)
 }
 
 // Validate vault configuration section.
 func (c *Configuration) Validate(valid *validation.Validate) error {
-	return valid.Struct(c)
+	if err := valid.Struct(c); err != nil {
+		return err
+	}
+
+	return c.Client.Validate(valid)
 }
@@ -0,0 +1,57 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package vault
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"git.zzdats.lv/edim/api-mdl/upstream"
+)
+
+// ConflictError is returned when secret has been changed since the version used for check-and-set was read.
+type ConflictError struct {
+	// Version is the secret version that was expected to be current.
+	Version int
+}
+
+func (e *ConflictError) Error() string {
+	return "vault secret has been changed since version " + strconv.Itoa(e.Version) + " was read"
+}
+
+// isCASMismatch checks if Vault has rejected write because of check-and-set version mismatch.
+func isCASMismatch(err error, errs []string) bool {
+	if err != nil && strings.Contains(err.Error(), "check-and-set") {
+		return true
+	}
+
+	for _, e := range errs {
+		if strings.Contains(e, "check-and-set") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isInvalidToken checks if Vault has rejected request because token is expired, revoked or unknown.
+func isInvalidToken(err error, errs []string) bool {
+	var se *upstream.StatusError
+	if errors.As(err, &se) && se.StatusCode == http.StatusForbidden {
+		return true
+	}
+
+	if err != nil && strings.Contains(err.Error(), "invalid token") {
+		return true
+	}
+
+	for _, e := range errs {
+		if strings.Contains(e, "invalid token") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,120 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package vault
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"git.zzdats.lv/edim/api-mdl/metrics"
+	"git.zzdats.lv/edim/api-mdl/routes/responses"
+	"git.zzdats.lv/edim/api-mdl/tracing"
+	"git.zzdats.lv/edim/api-mdl/upstream"
+)
+
+// metadataURL returns KV v2 metadata endpoint URL for the CSDD password secret.
+func (s *vaultService) metadataURL() string {
+	if s.config.MetadataURL != "" {
+		return s.config.MetadataURL
+	}
+
+	return strings.Replace(s.config.DataURL, "/data/", "/metadata/", 1)
+}
+
+// GetCSDDMetadata returns metadata of the CSDD password secret.
+func (s *vaultService) GetCSDDMetadata(ctx context.Context) (_ *responses.VaultMetadataResponse, err error) {
+	ctx, span := tracing.Start(ctx, "vault.GetCSDDMetadata")
+	defer tracing.End(span, &err)
+
+	token, err := s.GetToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &responses.VaultMetadataResponse{}
+	start := time.Now()
+
+	err = s.http.GetJSON(
+		ctx,
+		s.metadataURL(),
+		response,
+		upstream.WithHeader("X-Vault-Token", token),
+	)
+
+	metrics.ObserveVault("metadata_read", start, outcome(err, response.Errors))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(response.Errors) > 0 {
+		return nil, fmt.Errorf("vault error: %s", response.Errors[0])
+	}
+
+	return response, nil
+}
+
+// GetCSDDVersions returns versions of the CSDD password secret that are
+// not deleted or destroyed, starting from the newest one.
+func (s *vaultService) GetCSDDVersions(ctx context.Context) (_ []int, err error) {
+	ctx, span := tracing.Start(ctx, "vault.GetCSDDVersions")
+	defer tracing.End(span, &err)
+
+	meta, err := s.GetCSDDMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]int, 0, len(meta.Data.Versions))
+
+	for key, v := range meta.Data.Versions {
+		if v.Destroyed || v.DeletionTime != "" {
+			continue
+		}
+
+		version, err := strconv.Atoi(key)
+		if err != nil {
+			continue
+		}
+
+		versions = append(versions, version)
+	}
+
+	slices.Sort(versions)
+	slices.Reverse(versions)
+
+	return versions, nil
+}
+
+// SetCSDDCustomMetadata replaces custom metadata of the CSDD password secret.
+func (s *vaultService) SetCSDDCustomMetadata(ctx context.Context, metadata map[string]string) (err error) {
+	ctx, span := tracing.Start(ctx, "vault.SetCSDDCustomMetadata")
+	defer tracing.End(span, &err)
+
+	token, err := s.GetToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	postData := &responses.VaultMetadataPostData{
+		CustomMetadata: metadata,
+	}
+
+	start := time.Now()
+
+	err = s.http.PostJSON(
+		ctx,
+		s.metadataURL(),
+		postData,
+		nil,
+		upstream.WithHeader("X-Vault-Token", token),
+	)
+
+	metrics.ObserveVault("metadata_write", start, outcome(err, nil))
+
+	return err
+}
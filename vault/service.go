@@ -3,15 +3,20 @@
 package vault
 
 import (
+	"context"
+
 	"git.zzdats.lv/edim/api-mdl/routes/responses"
 
-	"azugo.io/azugo"
 	"azugo.io/core"
 )
 
 type Service interface {
-	GetCSDDAuthData(ctx *azugo.Context, version int) (*responses.VaultGetDataResponse, error)
-	ChangeVaultData(ctx *azugo.Context, newpsw string) (*responses.VaultSaveDataPostResponse, error)
+	GetToken(ctx context.Context) (string, error)
+	GetCSDDAuthData(ctx context.Context, version int) (*responses.VaultGetDataResponse, error)
+	ChangeVaultData(ctx context.Context, newpsw string, version int) (*responses.VaultSaveDataPostResponse, error)
+	GetCSDDMetadata(ctx context.Context) (*responses.VaultMetadataResponse, error)
+	GetCSDDVersions(ctx context.Context) ([]int, error)
+	SetCSDDCustomMetadata(ctx context.Context, metadata map[string]string) error
 }
 
 func New(app *core.App, config *Configuration) (Service, error) {
@@ -1,24 +1,32 @@
 package vault
 
 import (
+	"context"
 	"fmt"
 	"strconv"
-	"strings"
 	"sync"
 	"time"
 
+	"git.zzdats.lv/edim/api-mdl/metrics"
 	"git.zzdats.lv/edim/api-mdl/routes/responses"
+	"git.zzdats.lv/edim/api-mdl/tracing"
+	"git.zzdats.lv/edim/api-mdl/upstream"
 
-	"azugo.io/azugo"
 	"azugo.io/core"
 	"azugo.io/core/cache"
-	"azugo.io/core/http"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
 )
 
+// maxReadAttempts is the number of times secret read is attempted if Vault rejects the token.
+const maxReadAttempts = 3
+
 type vaultService struct {
 	app     *core.App
 	config  *Configuration
 	cache   cache.Instance[string]
+	http    *upstream.Client
 	tokenMu sync.RWMutex
 }
 
@@ -28,33 +36,67 @@ func newVaultService(app *core.App, config *Configuration) (Service, error) {
 		return nil, err
 	}
 
+	client, err := upstream.New(app.AppName, config.Client)
+	if err != nil {
+		return nil, err
+	}
+
 	s := &vaultService{
 		app:    app,
 		config: config,
 		cache:  cache,
+		http:   client,
 	}
 
 	return s, nil
 }
 
-func (s *vaultService) GetToken(ctx *azugo.Context) (string, error) {
-	s.tokenMu.RLock()
-	defer s.tokenMu.RUnlock()
+func (s *vaultService) log(ctx context.Context) *zap.Logger {
+	if c, ok := tracing.RequestContext(ctx); ok {
+		return c.Log()
+	}
+
+	return s.app.Log()
+}
+
+// outcome returns metrics outcome of Vault request.
+func outcome(err error, errs []string) string {
+	if err != nil || len(errs) > 0 {
+		return metrics.OutcomeError
+	}
+
+	return metrics.OutcomeSuccess
+}
+
+func (s *vaultService) GetToken(ctx context.Context) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "vault.GetToken")
+	defer tracing.End(span, &err)
 
+	s.tokenMu.RLock()
 	token, err := s.cache.Get(ctx, "secret_id")
+	s.tokenMu.RUnlock()
+
 	if err == nil && token != "" {
+		span.SetAttributes(attribute.Bool("vault.token.cached", true))
+
 		return token, nil
 	}
 
-	s.tokenMu.RUnlock()
+	span.SetAttributes(attribute.Bool("vault.token.cached", false))
+
 	s.tokenMu.Lock()
+	defer s.tokenMu.Unlock()
 
-	response := &responses.VaultGetTokenResponse{}
-	client := ctx.HTTPClient()
+	// token could have been refreshed while waiting for the lock
+	if token, err := s.cache.Get(ctx, "secret_id"); err == nil && token != "" {
+		return token, nil
+	}
 
-	ctx.Log().Debug("===> start get vault token")
+	response := &responses.VaultGetTokenResponse{}
+	s.log(ctx).Debug("===> start get vault token")
 
-	err = client.PostJSON(
+	err = s.http.PostJSON(
+		ctx,
 		s.config.LoginURL, // "https://vault.zzdats.lv/v1/auth/lvrtc-edim/login",
 		struct {
 			RoleID   string `json:"role_id"`
@@ -65,17 +107,14 @@ func (s *vaultService) GetToken(ctx *azugo.Context) (string, error) {
 		},
 		response,
 	)
-	if err != nil {
-		s.tokenMu.Unlock()
-		s.tokenMu.RLock()
 
+	metrics.ObserveVaultTokenRefresh(outcome(err, response.Errors))
+
+	if err != nil {
 		return "", err
 	}
 
 	if len(response.Errors) > 0 {
-		s.tokenMu.Unlock()
-		s.tokenMu.RLock()
-
 		return "", fmt.Errorf("vault error: %s", response.Errors[0])
 	}
 
@@ -83,15 +122,26 @@ func (s *vaultService) GetToken(ctx *azugo.Context) (string, error) {
 		return "", err
 	}
 
-	s.tokenMu.Unlock()
-	s.tokenMu.RLock()
-
-	ctx.Log().Debug("===> finish get vault token")
+	s.log(ctx).Debug("===> finish get vault token")
 
 	return response.Auth.ClientToken, nil
 }
 
-func (s *vaultService) GetCSDDAuthData(ctx *azugo.Context, version int) (*responses.VaultGetDataResponse, error) {
+// invalidateToken removes token rejected by Vault from the cache, unless it has been already refreshed.
+func (s *vaultService) invalidateToken(ctx context.Context, token string) {
+	s.tokenMu.Lock()
+	defer s.tokenMu.Unlock()
+
+	if cached, err := s.cache.Get(ctx, "secret_id"); err == nil && cached == token {
+		_ = s.cache.Delete(ctx, "secret_id")
+	}
+}
+
+// GetCSDDAuthData returns the given version of the CSDD password secret or the latest one if version is 0.
+func (s *vaultService) GetCSDDAuthData(ctx context.Context, version int) (_ *responses.VaultGetDataResponse, err error) {
+	ctx, span := tracing.Start(ctx, "vault.GetCSDDAuthData", trace.WithAttributes(attribute.Int("vault.secret.version", version)))
+	defer tracing.End(span, &err)
+
 	token, err := s.GetToken(ctx)
 	if err != nil {
 		return nil, err
@@ -102,52 +152,52 @@ func (s *vaultService) GetCSDDAuthData(ctx *azugo.Context, version int) (*respon
 	return response, err
 }
 
-func (s *vaultService) getVaultCSDDAuthData(ctx *azugo.Context, token string, version int) (*responses.VaultGetDataResponse, error) {
-	response := &responses.VaultGetDataResponse{}
-
-	client := ctx.HTTPClient()
+func (s *vaultService) getVaultCSDDAuthData(ctx context.Context, token string, version int) (*responses.VaultGetDataResponse, error) {
 	link := s.config.DataURL
 
 	if version > 0 {
-		link = link + "?version=" + strconv.Itoa(version-1)
+		link = link + "?version=" + strconv.Itoa(version)
 	}
 
-	maxRetries := map[int]int{0: 3}
+	for attempt := 1; ; attempt++ {
+		response := &responses.VaultGetDataResponse{}
+		start := time.Now()
 
-	var (
-		lastErr error
-		retry   int
-	)
-
-	for retry = range maxRetries {
-		err := client.GetJSON(
+		err := s.http.GetJSON(
+			ctx,
 			link,
 			response,
-			http.WithHeader("X-Vault-Token", token),
+			upstream.WithHeader("X-Vault-Token", token),
 		)
+
+		metrics.ObserveVault("read", start, outcome(err, response.Errors))
+
 		if err == nil {
 			return response, nil
 		}
 
-		lastErr = err
-
-		if !strings.Contains(err.Error(), "invalid token") {
-			break
+		if !isInvalidToken(err, response.Errors) || attempt >= maxReadAttempts {
+			return nil, fmt.Errorf("failed after %d attempts: %w", attempt, err)
 		}
 
+		// token has expired or has been revoked before cache TTL, so log in again
+		s.invalidateToken(ctx, token)
+
 		token, err = s.GetToken(ctx)
 		if err != nil {
-			continue
+			return nil, err
 		}
 	}
-
-	return nil, fmt.Errorf("failed after %d retries: %w", retry, lastErr)
 }
 
-func (s *vaultService) ChangeVaultData(ctx *azugo.Context, newPsw string) (*responses.VaultSaveDataPostResponse, error) {
-	result := &responses.VaultSaveDataPostResponse{}
+// ChangeVaultData saves new password to Vault only if the current secret version is still the given version.
+//
+// Returns *ConflictError if secret has been changed in the meantime.
+func (s *vaultService) ChangeVaultData(ctx context.Context, newPsw string, version int) (_ *responses.VaultSaveDataPostResponse, err error) {
+	ctx, span := tracing.Start(ctx, "vault.ChangeVaultData", trace.WithAttributes(attribute.Int("vault.secret.cas", version)))
+	defer tracing.End(span, &err)
 
-	client := ctx.HTTPClient()
+	result := &responses.VaultSaveDataPostResponse{}
 
 	token, err := s.GetToken(ctx)
 	if err != nil {
@@ -155,17 +205,32 @@ func (s *vaultService) ChangeVaultData(ctx *azugo.Context, newPsw string) (*resp
 	}
 
 	postData := &responses.VaultPostData{}
+	postData.Options.CAS = version
 	postData.Data.Password = newPsw
 
-	err = client.PostJSON(
+	start := time.Now()
+
+	err = s.http.PostJSON(
+		ctx,
 		s.config.DataURL,
 		postData,
 		result,
-		http.WithHeader("X-Vault-Token", token),
+		upstream.WithHeader("X-Vault-Token", token),
 	)
+
+	metrics.ObserveVault("write", start, outcome(err, result.Errors))
+
+	if isCASMismatch(err, result.Errors) {
+		return nil, &ConflictError{Version: version}
+	}
+
 	if err != nil {
 		return nil, err
 	}
 
+	if len(result.Errors) > 0 {
+		return nil, fmt.Errorf("vault error: %s", result.Errors[0])
+	}
+
 	return result, nil
 }
@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+package vaultmock
+
+import (
+	"strconv"
+	"time"
+)
+
+// secret is KV v2 secret with all its versions.
+type secret struct {
+	created        time.Time
+	updated        time.Time
+	current        int
+	oldest         int
+	customMetadata map[string]string
+	versions       map[int]*version
+}
+
+type version struct {
+	data      map[string]any
+	created   time.Time
+	deleted   time.Time
+	destroyed bool
+}
+
+func newSecret() *secret {
+	now := time.Now().UTC()
+
+	return &secret{
+		created:        now,
+		updated:        now,
+		customMetadata: make(map[string]string),
+		versions:       make(map[int]*version),
+	}
+}
+
+// put adds new version of the secret and prunes the oldest versions above the limit.
+func (s *secret) put(data map[string]any, maxVersions int) int {
+	now := time.Now().UTC()
+
+	s.current++
+	s.updated = now
+	s.versions[s.current] = &version{
+		data:    data,
+		created: now,
+	}
+
+	if s.oldest == 0 {
+		s.oldest = s.current
+	}
+
+	for maxVersions > 0 && s.current-s.oldest >= maxVersions {
+		delete(s.versions, s.oldest)
+		s.oldest++
+	}
+
+	return s.current
+}
+
+func (v *version) metadata(n int, customMetadata map[string]string) map[string]any {
+	return map[string]any{
+		"created_time":    v.created.Format(time.RFC3339Nano),
+		"custom_metadata": customMetadata,
+		"deletion_time":   formatDeletion(v.deleted),
+		"destroyed":       v.destroyed,
+		"version":         n,
+	}
+}
+
+func (s *secret) metadata(maxVersions int) map[string]any {
+	versions := make(map[string]any, len(s.versions))
+	for n, v := range s.versions {
+		versions[strconv.Itoa(n)] = map[string]any{
+			"created_time":  v.created.Format(time.RFC3339Nano),
+			"deletion_time": formatDeletion(v.deleted),
+			"destroyed":     v.destroyed,
+		}
+	}
+
+	return map[string]any{
+		"created_time":    s.created.Format(time.RFC3339Nano),
+		"updated_time":    s.updated.Format(time.RFC3339Nano),
+		"current_version": s.current,
+		"oldest_version":  s.oldest,
+		"max_versions":    maxVersions,
+		"custom_metadata": s.customMetadata,
+		"versions":        versions,
+	}
+}
+
+func formatDeletion(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.Format(time.RFC3339Nano)
+}
@@ -0,0 +1,610 @@
+// SPDX-License-Identifier: EUPL-1.2
+
+// Package vaultmock implements Vault AppRole login and KV v2 secrets engine stand-in for development and tests.
+package vaultmock
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Operations that faults can be injected into.
+const (
+	OperationLogin         = "login"
+	OperationRead          = "read"
+	OperationWrite         = "write"
+	OperationMetadataRead  = "metadata_read"
+	OperationMetadataWrite = "metadata_write"
+)
+
+// invalidTokenError is the error Vault returns for unknown, expired or revoked token.
+const invalidTokenError = "2 errors occurred:\n\t* permission denied\n\t* invalid token\n\n"
+
+// Options for Vault mock.
+type Options struct {
+	// RoleID and SecretID are the AppRole credentials accepted by login.
+	RoleID   string
+	SecretID string
+	// TokenTTL is the lifetime of issued tokens, 0 for no expiry.
+	TokenTTL time.Duration
+	// MaxVersions is the number of secret versions kept, 0 for 10.
+	MaxVersions int
+}
+
+// Fault is an error injected into responses of matching requests.
+type Fault struct {
+	// Operation the fault applies to, empty for all operations.
+	Operation string
+	// After is the number of matching requests to pass before fault is applied.
+	After int
+	// Times is the number of requests fault is applied to, 0 for all.
+	Times int
+
+	// Delay delays the response.
+	Delay time.Duration
+	// Status is the HTTP status code returned, 500 if not set.
+	Status int
+	// Errors are returned in response body.
+	Errors []string
+	// InvalidToken revokes request token and rejects request with invalid token error.
+	InvalidToken bool
+
+	hits    int
+	applied int
+}
+
+// Server is the Vault mock HTTP handler.
+type Server struct {
+	opts Options
+
+	mu       sync.Mutex
+	tokens   map[string]time.Time
+	secrets  map[string]*secret
+	faults   []*Fault
+	requests map[string]int
+}
+
+// New returns Vault mock with no secrets.
+func New(opts Options) *Server {
+	if opts.MaxVersions == 0 {
+		opts.MaxVersions = 10
+	}
+
+	return &Server{
+		opts:     opts,
+		tokens:   make(map[string]time.Time),
+		secrets:  make(map[string]*secret),
+		requests: make(map[string]int),
+	}
+}
+
+// Put writes new version of the secret and returns its version.
+//
+// Path consists of secrets engine mount and secret path, e.g. "secret/csdd" for "/v1/secret/data/csdd".
+func (s *Server) Put(path string, data map[string]any) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sec, ok := s.secrets[path]
+	if !ok {
+		sec = newSecret()
+		s.secrets[path] = sec
+	}
+
+	return sec.put(maps.Clone(data), s.opts.MaxVersions)
+}
+
+// Get returns data of the secret version or the current version if version is 0.
+func (s *Server) Get(path string, version int) (map[string]any, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sec, ok := s.secrets[path]
+	if !ok {
+		return nil, false
+	}
+
+	if version == 0 {
+		version = sec.current
+	}
+
+	v, ok := sec.versions[version]
+	if !ok || v.destroyed || !v.deleted.IsZero() {
+		return nil, false
+	}
+
+	return maps.Clone(v.data), true
+}
+
+// CurrentVersion returns the current version of the secret, 0 if it does not exist.
+func (s *Server) CurrentVersion(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sec, ok := s.secrets[path]; ok {
+		return sec.current
+	}
+
+	return 0
+}
+
+// CustomMetadata returns custom metadata of the secret.
+func (s *Server) CustomMetadata(path string) map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sec, ok := s.secrets[path]; ok {
+		return maps.Clone(sec.customMetadata)
+	}
+
+	return nil
+}
+
+// SetCustomMetadata replaces custom metadata of the existing secret.
+func (s *Server) SetCustomMetadata(path string, metadata map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sec, ok := s.secrets[path]; ok {
+		sec.customMetadata = maps.Clone(metadata)
+	}
+}
+
+// DeleteVersion soft deletes the secret version.
+func (s *Server) DeleteVersion(path string, version int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sec, ok := s.secrets[path]; ok {
+		if v, ok := sec.versions[version]; ok {
+			v.deleted = time.Now().UTC()
+		}
+	}
+}
+
+// DestroyVersion permanently removes data of the secret version.
+func (s *Server) DestroyVersion(path string, version int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sec, ok := s.secrets[path]; ok {
+		if v, ok := sec.versions[version]; ok {
+			v.destroyed = true
+			v.data = nil
+		}
+	}
+}
+
+// ExpireTokens invalidates all issued tokens.
+func (s *Server) ExpireTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	clear(s.tokens)
+}
+
+// AddFault injects fault into responses of matching requests.
+func (s *Server) AddFault(f *Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = append(s.faults, f)
+}
+
+// ClearFaults removes all injected faults.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = nil
+}
+
+// Requests returns the number of requests received for the operation.
+func (s *Server) Requests(operation string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests[operation]
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path, ok := strings.CutPrefix(r.URL.Path, "/v1/")
+	if !ok {
+		writeErrors(w, http.StatusNotFound)
+
+		return
+	}
+
+	if strings.HasPrefix(path, "auth/") && strings.HasSuffix(path, "/login") {
+		if r.Method != http.MethodPost && r.Method != http.MethodPut {
+			writeErrors(w, http.StatusMethodNotAllowed)
+
+			return
+		}
+
+		if s.inject(w, r, OperationLogin) {
+			return
+		}
+
+		s.login(w, r)
+
+		return
+	}
+
+	mount, secretPath, kind := splitPath(path)
+	if kind == "" {
+		writeErrors(w, http.StatusNotFound)
+
+		return
+	}
+
+	var operation string
+
+	switch {
+	case kind == "data" && r.Method == http.MethodGet:
+		operation = OperationRead
+	case kind == "data" && (r.Method == http.MethodPost || r.Method == http.MethodPut):
+		operation = OperationWrite
+	case kind == "metadata" && r.Method == http.MethodGet:
+		operation = OperationMetadataRead
+	case kind == "metadata" && (r.Method == http.MethodPost || r.Method == http.MethodPut):
+		operation = OperationMetadataWrite
+	default:
+		writeErrors(w, http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	if s.inject(w, r, operation) {
+		return
+	}
+
+	if !s.validToken(r.Header.Get("X-Vault-Token")) {
+		writeErrors(w, http.StatusForbidden, invalidTokenError)
+
+		return
+	}
+
+	key := mount + "/" + secretPath
+
+	switch operation {
+	case OperationRead:
+		s.read(w, r, key)
+	case OperationWrite:
+		s.write(w, r, key)
+	case OperationMetadataRead:
+		s.readMetadata(w, key)
+	case OperationMetadataWrite:
+		s.writeMetadata(w, r, key)
+	}
+}
+
+// inject applies the first matching fault and returns true if response has been written.
+func (s *Server) inject(w http.ResponseWriter, r *http.Request, operation string) bool {
+	s.mu.Lock()
+
+	s.requests[operation]++
+
+	var fault *Fault
+
+	for _, f := range s.faults {
+		if f.Operation != "" && f.Operation != operation {
+			continue
+		}
+
+		f.hits++
+		if f.hits <= f.After || (f.Times > 0 && f.applied >= f.Times) {
+			continue
+		}
+
+		f.applied++
+		fault = f
+
+		if f.InvalidToken {
+			delete(s.tokens, r.Header.Get("X-Vault-Token"))
+		}
+
+		break
+	}
+
+	s.mu.Unlock()
+
+	if fault == nil {
+		return false
+	}
+
+	if fault.Delay > 0 {
+		select {
+		case <-r.Context().Done():
+			return true
+		case <-time.After(fault.Delay):
+		}
+	}
+
+	switch {
+	case fault.Status != 0 || len(fault.Errors) > 0:
+		status := fault.Status
+		if status == 0 {
+			status = http.StatusInternalServerError
+		}
+
+		writeErrors(w, status, fault.Errors...)
+
+		return true
+	case fault.InvalidToken:
+		writeErrors(w, http.StatusForbidden, invalidTokenError)
+
+		return true
+	}
+
+	// only delayed
+	return false
+}
+
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	body := &struct {
+		RoleID   string `json:"role_id"`
+		SecretID string `json:"secret_id"`
+	}{}
+
+	if err := json.NewDecoder(r.Body).Decode(body); err != nil {
+		writeErrors(w, http.StatusBadRequest, err.Error())
+
+		return
+	}
+
+	if body.RoleID != s.opts.RoleID || body.SecretID != s.opts.SecretID {
+		writeErrors(w, http.StatusBadRequest, "invalid role or secret ID")
+
+		return
+	}
+
+	token := "hvs." + randomID()
+
+	var expires time.Time
+	if s.opts.TokenTTL > 0 {
+		expires = time.Now().Add(s.opts.TokenTTL)
+	}
+
+	s.mu.Lock()
+	s.tokens[token] = expires
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"request_id":     randomID(),
+		"lease_id":       "",
+		"renewable":      false,
+		"lease_duration": 0,
+		"data":           nil,
+		"wrap_info":      nil,
+		"warnings":       nil,
+		"auth": map[string]any{
+			"client_token":   token,
+			"accessor":       randomID(),
+			"policies":       []string{"default"},
+			"token_policies": []string{"default"},
+			"metadata":       map[string]string{"role_name": "mock"},
+			"lease_duration": int(s.opts.TokenTTL.Seconds()),
+			"renewable":      true,
+			"entity_id":      "",
+			"token_type":     "service",
+			"orphan":         true,
+			"num_uses":       0,
+		},
+		"mount_type": "",
+	})
+}
+
+func (s *Server) validToken(token string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expires, ok := s.tokens[token]
+	if !ok {
+		return false
+	}
+
+	if !expires.IsZero() && time.Now().After(expires) {
+		delete(s.tokens, token)
+
+		return false
+	}
+
+	return true
+}
+
+func (s *Server) read(w http.ResponseWriter, r *http.Request, key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sec, ok := s.secrets[key]
+	if !ok {
+		writeErrors(w, http.StatusNotFound)
+
+		return
+	}
+
+	n := sec.current
+
+	if q := r.URL.Query().Get("version"); q != "" {
+		var err error
+
+		n, err = strconv.Atoi(q)
+		if err != nil {
+			writeErrors(w, http.StatusBadRequest, "invalid version")
+
+			return
+		}
+
+		if n == 0 {
+			n = sec.current
+		}
+	}
+
+	v, ok := sec.versions[n]
+	if !ok {
+		writeErrors(w, http.StatusNotFound)
+
+		return
+	}
+
+	// deleted and destroyed versions are returned with 404 and metadata only
+	status := http.StatusOK
+	data := v.data
+
+	if v.destroyed || !v.deleted.IsZero() {
+		status = http.StatusNotFound
+		data = nil
+	}
+
+	writeJSON(w, status, map[string]any{
+		"request_id":     randomID(),
+		"lease_id":       "",
+		"renewable":      false,
+		"lease_duration": 0,
+		"data": map[string]any{
+			"data":     data,
+			"metadata": v.metadata(n, sec.customMetadata),
+		},
+		"wrap_info":  nil,
+		"warnings":   nil,
+		"auth":       nil,
+		"mount_type": "kv",
+	})
+}
+
+func (s *Server) write(w http.ResponseWriter, r *http.Request, key string) {
+	body := &struct {
+		Options struct {
+			CAS *int `json:"cas"`
+		} `json:"options"`
+		Data map[string]any `json:"data"`
+	}{}
+
+	if err := json.NewDecoder(r.Body).Decode(body); err != nil {
+		writeErrors(w, http.StatusBadRequest, err.Error())
+
+		return
+	}
+
+	if body.Data == nil {
+		writeErrors(w, http.StatusBadRequest, "no data provided")
+
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sec, ok := s.secrets[key]
+	if !ok {
+		sec = newSecret()
+	}
+
+	if body.Options.CAS != nil && *body.Options.CAS != sec.current {
+		writeErrors(w, http.StatusBadRequest, "check-and-set parameter did not match the current version")
+
+		return
+	}
+
+	s.secrets[key] = sec
+
+	n := sec.put(body.Data, s.opts.MaxVersions)
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"request_id":     randomID(),
+		"lease_id":       "",
+		"renewable":      false,
+		"lease_duration": 0,
+		"data":           sec.versions[n].metadata(n, sec.customMetadata),
+		"wrap_info":      nil,
+		"warnings":       nil,
+		"auth":           nil,
+		"mount_type":     "kv",
+	})
+}
+
+func (s *Server) readMetadata(w http.ResponseWriter, key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sec, ok := s.secrets[key]
+	if !ok {
+		writeErrors(w, http.StatusNotFound)
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"request_id": randomID(),
+		"data":       sec.metadata(s.opts.MaxVersions),
+	})
+}
+
+func (s *Server) writeMetadata(w http.ResponseWriter, r *http.Request, key string) {
+	body := &struct {
+		CustomMetadata map[string]string `json:"custom_metadata"`
+	}{}
+
+	if err := json.NewDecoder(r.Body).Decode(body); err != nil {
+		writeErrors(w, http.StatusBadRequest, err.Error())
+
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sec, ok := s.secrets[key]
+	if !ok {
+		sec = newSecret()
+		s.secrets[key] = sec
+	}
+
+	if body.CustomMetadata != nil {
+		sec.customMetadata = body.CustomMetadata
+	}
+
+	sec.updated = time.Now().UTC()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// splitPath splits KV v2 API path into mount, secret path and endpoint kind (data or metadata).
+func splitPath(path string) (string, string, string) {
+	for _, kind := range []string{"data", "metadata"} {
+		if mount, secretPath, ok := strings.Cut(path, "/"+kind+"/"); ok && mount != "" && secretPath != "" {
+			return mount, secretPath, kind
+		}
+	}
+
+	return "", "", ""
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeErrors(w http.ResponseWriter, status int, errs ...string) {
+	if errs == nil {
+		errs = []string{}
+	}
+
+	writeJSON(w, status, map[string]any{"errors": errs})
+}
+
+func randomID() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}